@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"dockship/internal/delta"
+
+	"github.com/spf13/cobra"
+)
+
+var deltaBlockSize int
+
+// deltaCmd 二进制增量的远程辅助命令
+// 开启 transfer.delta_helper 时 dockship 将自身上传到远程主机，通过这些命令在远程计算签名和重建镜像文件
+var deltaCmd = &cobra.Command{
+	Use:    "delta",
+	Short:  "二进制增量辅助命令（在远程主机上执行）",
+	Hidden: true,
+}
+
+// deltaSignatureCmd 计算基准文件的块签名，输出到标准输出
+var deltaSignatureCmd = &cobra.Command{
+	Use:   "signature <基准文件>",
+	Short: "计算文件的块签名",
+	Args:  cobra.ExactArgs(1),
+
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("打开基准文件失败: %w", err)
+		}
+		defer file.Close()
+
+		sig, err := delta.ComputeSignature(file, deltaBlockSize)
+		if err != nil {
+			return err
+		}
+		_, err = sig.WriteTo(os.Stdout)
+		return err
+	},
+}
+
+// deltaPatchCmd 将增量应用到基准文件，重建新文件
+var deltaPatchCmd = &cobra.Command{
+	Use:   "patch <基准文件> <增量文件> <输出文件>",
+	Short: "根据增量重建文件",
+	Args:  cobra.ExactArgs(3),
+
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		basis, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("打开基准文件失败: %w", err)
+		}
+		defer basis.Close()
+
+		deltaFile, err := os.Open(args[1])
+		if err != nil {
+			return fmt.Errorf("打开增量文件失败: %w", err)
+		}
+		defer deltaFile.Close()
+
+		out, err := os.Create(args[2])
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		err = delta.Patch(basis, deltaFile, out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(args[2])
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deltaCmd)
+	deltaCmd.AddCommand(deltaSignatureCmd, deltaPatchCmd)
+	deltaSignatureCmd.Flags().IntVar(&deltaBlockSize, "block-size", delta.DefaultBlockSize, "块大小（字节）")
+}
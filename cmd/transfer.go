@@ -3,11 +3,14 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"dockship/internal/config"
 	"dockship/internal/transfer"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var skipConfirm bool
@@ -30,6 +33,8 @@ var transferCmd = &cobra.Command{
   dockship go                          # 等同于 transfer，更简短的别名
   dockship transfer -c custom.yaml     # 使用自定义配置文件
   dockship transfer -y                 # 跳过二次确认
+  dockship go -i hosts.ini             # 从 Ansible 主机清单加载目标主机
+  dockship go --force                  # 远程已有相同的镜像时仍然传输
   dockship go -c custom.yaml     # 使用自定义配置文件`,
 	RunE: runTransfer,
 }
@@ -38,6 +43,10 @@ func init() {
 	// 将传输命令添加到根命令
 	rootCmd.AddCommand(transferCmd)
 	transferCmd.Flags().BoolVarP(&skipConfirm, "yes", "y", false, "跳过二次确认，直接执行")
+	transferCmd.Flags().StringP("inventory", "i", "", "Ansible 主机清单文件（INI/YAML），覆盖配置文件中的 inventory")
+	viper.BindPFlag("inventory", transferCmd.Flags().Lookup("inventory"))
+	transferCmd.Flags().Bool("force", false, "远程主机已有相同的镜像（镜像ID一致）时仍然传输")
+	viper.BindPFlag("transfer.force", transferCmd.Flags().Lookup("force"))
 }
 
 // runTransfer 执行传输任务
@@ -68,24 +77,88 @@ func printConfigInfo(cfg *config.Config, skipConfirm bool) {
 	fmt.Println("\n📋 配置信息：")
 	fmt.Printf("  镜像数量: %d\n", len(cfg.Images))
 	for i, imageCfg := range cfg.Images {
-		fmt.Printf("    %d. %s\n", i+1, imageCfg.Name)
+		fmt.Printf("    %d. %s%s\n", i+1, imageCfg.Name, describeImagePlan(cfg, imageCfg))
 	}
-	fmt.Printf("  目标主机: %d 台\n", len(cfg.TargetHosts))
-	for i, target := range cfg.TargetHosts {
-		fmt.Printf("    %d. %s\n", i+1, target)
+	if cfg.Inventory != "" {
+		fmt.Printf("  主机清单: %s\n", cfg.Inventory)
+	}
+	if summary := cfg.ExpansionSummary(); summary != "" {
+		fmt.Printf("  主机范围: %s\n", summary)
+	}
+	if len(cfg.Excluded) > 0 {
+		fmt.Printf("  目标主机: %d 台（已排除 %d 台: %s）\n", len(cfg.Targets), len(cfg.Excluded), strings.Join(cfg.Excluded, ", "))
+	} else {
+		fmt.Printf("  目标主机: %d 台\n", len(cfg.Targets))
+	}
+	for i, target := range cfg.Targets {
+		fmt.Printf("    %d. %s\n", i+1, describeTarget(cfg, &target))
+	}
+	if len(cfg.HostGroups) > 0 {
+		fmt.Printf("  主机组: %d 个\n", len(cfg.HostGroups))
+		for _, group := range cfg.HostGroups {
+			fmt.Printf("    %s: %s\n", group.Name, strings.Join(group.Hosts, ", "))
+		}
 	}
 	fmt.Printf("  并发数: %d\n", cfg.Transfer.Concurrent)
 	fmt.Printf("  重试次数: %d\n", cfg.Transfer.Retry)
+	if cfg.Transfer.Relay.Enabled {
+		seed := "第一台目标主机"
+		if cfg.RelaySeed != nil {
+			seed = cfg.RelaySeed.Name
+		}
+		fmt.Printf("  中继分发: 种子主机 %s，每台主机最多转发 %d 台\n", seed, cfg.Transfer.Relay.Fanout)
+	} else if cfg.Transfer.Multicast {
+		fmt.Printf("  批量分发: 每批 %d 台共享一次读取，缓冲 %d MB/台，慢主机 %d 秒后脱离\n",
+			cfg.Transfer.Concurrent, cfg.Transfer.MulticastBuffer, cfg.Transfer.SlowHostTimeout)
+	}
+	if cfg.Transfer.Mode == "stream" {
+		fmt.Printf("  传输模式: stream（docker save 直接导入远程 docker load，不落盘）\n")
+	} else {
+		fmt.Printf("  上传方式: %s\n", describeUploadMethod(cfg.Transfer.UploadMethod))
+	}
+	if cfg.Transfer.Compression != "none" {
+		fmt.Printf("  传输压缩: %s\n", describeCompression(cfg.Transfer.Compression, cfg.Transfer.CompressionLevel))
+	}
+	if cfg.Transfer.LayerDelta {
+		fmt.Printf("  层级增量: 只发送远程缺少的镜像层\n")
+	}
+	if cfg.Transfer.BinaryDelta {
+		fmt.Printf("  二进制增量: 只发送与远程缓存（%s）中上一版本的差异\n", cfg.RemoteStorage.CacheDir)
+		if cfg.Transfer.DeltaHelper {
+			fmt.Printf("  增量辅助程序: 上传 dockship 自身到远程计算签名和重建（每台主机每个版本上传一次）\n")
+		}
+	}
+	if cfg.Transfer.VerifyChecksum && cfg.Transfer.Mode != "stream" {
+		fmt.Printf("  校验和: SHA-256（加载前校验，不一致时重新上传）\n")
+	}
+	if cfg.Transfer.StallTimeout > 0 {
+		fmt.Printf("  停滞超时: %d 秒\n", cfg.Transfer.StallTimeout)
+	}
+	if cfg.Transfer.BandwidthRate > 0 {
+		fmt.Printf("  上传限速: 总计 %s，由进行中的上传平分（运行中发送 SIGHUP 可重新读取）\n", config.FormatBandwidth(cfg.Transfer.BandwidthRate))
+	}
 	fmt.Printf("  自动加载镜像: %v\n", cfg.Transfer.AutoLoad)
-	fmt.Printf("  SSH用户: %s\n", cfg.SSH.User)
+	if cfg.Transfer.Force {
+		fmt.Printf("  强制传输: 远程已有相同的镜像时仍然传输\n")
+	}
+	if cfg.SSH.User != "" {
+		fmt.Printf("  SSH用户: %s\n", cfg.SSH.User)
+	}
 	fmt.Printf("  SSH端口: %d\n", cfg.SSH.Port)
+	if cfg.SSH.KeepaliveInterval > 0 {
+		fmt.Printf("  SSH保活: 每 %d 秒，连续 %d 次无响应断开\n", cfg.SSH.KeepaliveInterval, cfg.SSH.KeepaliveCountMax)
+	}
 
-	// 检查是否使用密钥认证
-	authMethod := "密码"
-	if cfg.SSH.KeyFile != "" {
-		authMethod = fmt.Sprintf("密钥 (%s)", cfg.SSH.KeyFile)
+	fmt.Printf("  认证方式: %s\n", describeAuthMethods(&cfg.SSH))
+	if cfg.Become.Method != "" {
+		fmt.Printf("  提权方式: %s\n", describeBecome(cfg.Become))
+	}
+	if len(cfg.SSH.JumpHosts) > 0 {
+		fmt.Printf("  跳板机: %s\n", describeJumpHosts(cfg.SSH.JumpHosts))
+	}
+	for _, rule := range cfg.SSH.HostJumpHosts {
+		fmt.Printf("  跳板机 (%s): %s\n", strings.Join(rule.Hosts, ", "), describeJumpHosts(rule.JumpHosts))
 	}
-	fmt.Printf("  认证方式: %s\n", authMethod)
 
 	// 确认执行（配置开启确认且未指定 -y 时才询问）
 	if cfg.Transfer.Confirm && !skipConfirm {
@@ -99,3 +172,147 @@ func printConfigInfo(cfg *config.Config, skipConfirm bool) {
 	}
 	fmt.Println()
 }
+
+// describeImagePlan 描述镜像的分发计划，发送到全部主机时返回空
+func describeImagePlan(cfg *config.Config, imageCfg config.ImageConfig) string {
+	if len(imageCfg.Groups) == 0 && len(imageCfg.Hosts) == 0 && len(imageCfg.ExcludeHosts) == 0 {
+		return ""
+	}
+
+	targets := cfg.TargetsFor(imageCfg)
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+
+	var selectors []string
+	if len(imageCfg.Groups) > 0 {
+		selectors = append(selectors, "组 "+strings.Join(imageCfg.Groups, ","))
+	}
+	if len(imageCfg.Hosts) > 0 {
+		selectors = append(selectors, "主机 "+strings.Join(imageCfg.Hosts, ","))
+	}
+	if len(imageCfg.ExcludeHosts) > 0 {
+		selectors = append(selectors, "排除 "+strings.Join(imageCfg.ExcludeHosts, ","))
+	}
+	return fmt.Sprintf(" → %d 台 [%s]（%s）", len(targets), strings.Join(names, ", "), strings.Join(selectors, "；"))
+}
+
+// describeTarget 描述目标主机，展示实际的连接参数及其来源
+func describeTarget(cfg *config.Config, target *config.Target) string {
+	desc := target.Name
+	if target.Address != target.Name || target.User != cfg.SSH.User || target.Port != cfg.SSH.Port {
+		desc += " → " + target.String()
+	}
+	if len(target.JumpHosts) > 0 {
+		desc += fmt.Sprintf("，经由 %s", describeJumpHosts(target.JumpHosts))
+	}
+	if target.Become.Method != cfg.Become.Method || target.Become.User != cfg.Become.User {
+		desc += fmt.Sprintf("，提权 %s", describeBecome(target.Become))
+	}
+	if target.BandwidthLimit > 0 {
+		desc += fmt.Sprintf("，限速 %s", config.FormatBandwidth(target.BandwidthLimit))
+	}
+	if target.RemoteTempDir != cfg.RemoteStorage.TempDir {
+		desc += fmt.Sprintf("，远程目录 %s", target.RemoteTempDir)
+	}
+	if len(target.Labels) > 0 {
+		labels := make([]string, 0, len(target.Labels))
+		for k, v := range target.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		desc += fmt.Sprintf(" [%s]", strings.Join(labels, ","))
+	}
+	if len(target.SSHConfigKeys) > 0 {
+		desc += fmt.Sprintf("（ssh_config: %s）", strings.Join(target.SSHConfigKeys, ", "))
+	}
+	return desc
+}
+
+// describeBecome 描述提权配置
+func describeBecome(become config.BecomeConfig) string {
+	if become.Method == "" {
+		return "无"
+	}
+	desc := become.Method + " → " + become.User
+	if become.Password != "" || become.PasswordEnv != "" {
+		desc += "（密码）"
+	}
+	if become.Hooks {
+		desc += "，含 hooks"
+	}
+	return desc
+}
+
+// describeUploadMethod 描述上传方式
+func describeUploadMethod(method string) string {
+	switch method {
+	case "auto":
+		return "auto（优先 SFTP，不可用时改用 scp/cat）"
+	case "scp":
+		return "scp（SCP 协议）"
+	case "cat":
+		return "cat（exec 会话写入）"
+	}
+	return method
+}
+
+// describeCompression 描述传输压缩
+func describeCompression(codec string, level int) string {
+	desc := codec
+	if level > 0 {
+		desc = fmt.Sprintf("%s（级别 %d）", codec, level)
+	}
+	if codec == "zstd" {
+		desc += "，远程未安装 zstd 时改用 gzip"
+	}
+	return desc
+}
+
+// describeJumpHosts 描述跳板链
+func describeJumpHosts(hops []config.JumpHostConfig) string {
+	if len(hops) == 0 {
+		return "直连"
+	}
+	names := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		name := hop.Address
+		if hop.Port != 0 && hop.Port != 22 {
+			name = fmt.Sprintf("%s:%d", hop.Address, hop.Port)
+		}
+		if hop.User != "" {
+			name = hop.User + "@" + name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " → ")
+}
+
+// describeAuthMethods 按尝试顺序描述实际可用的认证方式
+func describeAuthMethods(sshCfg *config.SSHConfig) string {
+	var methods []string
+	for _, method := range sshCfg.AuthMethods {
+		switch method {
+		case "agent":
+			if os.Getenv("SSH_AUTH_SOCK") != "" {
+				methods = append(methods, "ssh-agent")
+			}
+		case "key":
+			if keyFiles := sshCfg.AllKeyFiles(); len(keyFiles) > 0 {
+				desc := strings.Join(keyFiles, ", ")
+				if sshCfg.CertFile != "" {
+					desc += "；证书 " + sshCfg.CertFile
+				}
+				methods = append(methods, fmt.Sprintf("密钥 (%s)", desc))
+			}
+		case "password":
+			if sshCfg.Password != "" {
+				methods = append(methods, "密码")
+			}
+		case "keyboard-interactive":
+			methods = append(methods, "键盘交互")
+		}
+	}
+	return strings.Join(methods, " → ")
+}
@@ -0,0 +1,159 @@
+// Package bandwidth 实现上传限速：全局总速率在进行中的上传之间公平分配，
+// 每台主机还可以单独限速，每个上传按分得的速率用令牌桶控制写入
+package bandwidth
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// burstDuration 令牌桶最多积累的时长，空闲后恢复上传时的突发量不超过该时长的配额
+const burstDuration = 200 * time.Millisecond
+
+// waitSlice 单次等待的最长时间，之后按最新的速率重新计算
+const waitSlice = 100 * time.Millisecond
+
+// Limiter 上传限速器，所有主机的上传共享
+type Limiter struct {
+	mu      sync.Mutex
+	total   int64            // 全局总速率（字节/秒），0 表示不限
+	hosts   map[string]int64 // 主机 -> 单独限速（字节/秒）
+	streams map[*Stream]struct{}
+}
+
+// New 创建限速器，total 和 hosts 中的值为 0 表示不限速
+func New(total int64, hosts map[string]int64) *Limiter {
+	return &Limiter{total: total, hosts: hosts, streams: make(map[*Stream]struct{})}
+}
+
+// Update 更新限速，立即应用到进行中的上传
+func (l *Limiter) Update(total int64, hosts map[string]int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total = total
+	l.hosts = hosts
+	l.rebalance()
+}
+
+// Open 开始一个上传，结束后需要调用 Close 把配额让给其他上传
+// l 为 nil 时返回 nil，nil 的 Stream 不限速
+func (l *Limiter) Open(host string) *Stream {
+	if l == nil {
+		return nil
+	}
+	s := &Stream{l: l, host: host}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.streams[s] = struct{}{}
+	l.rebalance()
+	return s
+}
+
+// rebalance 重新分配各上传的速率（注水算法）：单独限速低于平均值的上传按自身限速，
+// 剩余的总速率由其他上传平分；调用时需持有 l.mu
+func (l *Limiter) rebalance() {
+	streams := make([]*Stream, 0, len(l.streams))
+	for s := range l.streams {
+		streams = append(streams, s)
+	}
+	capOf := func(s *Stream) int64 { return l.hosts[s.host] }
+
+	if l.total <= 0 {
+		for _, s := range streams {
+			s.allotted = capOf(s)
+		}
+		return
+	}
+
+	// 按单独限速从小到大分配，没有单独限速的排在最后
+	slices.SortFunc(streams, func(a, b *Stream) int {
+		ca, cb := capOf(a), capOf(b)
+		switch {
+		case ca == cb:
+			return 0
+		case ca == 0:
+			return 1
+		case cb == 0:
+			return -1
+		case ca < cb:
+			return -1
+		}
+		return 1
+	})
+	remaining := l.total
+	for i, s := range streams {
+		share := remaining / int64(len(streams)-i)
+		if c := capOf(s); c > 0 && c < share {
+			share = c
+		}
+		s.allotted = max(share, 1)
+		remaining -= share
+	}
+}
+
+// Stream 单个上传的令牌桶
+type Stream struct {
+	l        *Limiter
+	host     string
+	allotted int64 // 分得的速率（字节/秒），0 表示不限，受 l.mu 保护
+
+	tokens float64 // 可用的字节数，为负表示已透支，只由上传所在的协程访问
+	last   time.Time
+}
+
+// Wait 写入 n 字节前调用，超出配额时等待
+// 先扣除令牌再按透支的量等待，单次写入大于桶容量时也能按速率平滑发送；
+// 等待分段进行，每段结束后按最新分得的速率补充令牌，运行中调整限速或其他上传结束时立即生效
+func (s *Stream) Wait(n int) {
+	if s == nil {
+		return
+	}
+	rate := s.refill()
+	if rate <= 0 {
+		return
+	}
+	s.tokens -= float64(n)
+	for s.tokens < 0 {
+		time.Sleep(min(time.Duration(-s.tokens/float64(rate)*float64(time.Second)), waitSlice))
+		if rate = s.refill(); rate <= 0 {
+			return
+		}
+	}
+}
+
+// refill 按当前分得的速率补充从上次补充到现在的令牌并返回该速率，不限速时清空令牌并返回 0
+func (s *Stream) refill() int64 {
+	rate := s.rate()
+	now := time.Now()
+	if rate <= 0 {
+		s.tokens, s.last = 0, now
+		return 0
+	}
+	if !s.last.IsZero() {
+		s.tokens += now.Sub(s.last).Seconds() * float64(rate)
+	}
+	if burst := burstDuration.Seconds() * float64(rate); s.tokens > burst {
+		s.tokens = burst
+	}
+	s.last = now
+	return rate
+}
+
+// rate 返回当前分得的速率
+func (s *Stream) rate() int64 {
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	return s.allotted
+}
+
+// Close 结束上传，其配额分给其他进行中的上传
+func (s *Stream) Close() {
+	if s == nil {
+		return
+	}
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	delete(s.l.streams, s)
+	s.l.rebalance()
+}
@@ -0,0 +1,104 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int64
+		hosts map[string]int64
+		open  []string
+		want  map[string]int64
+	}{
+		{
+			name:  "平分总速率",
+			total: 900,
+			open:  []string{"a", "b", "c"},
+			want:  map[string]int64{"a": 300, "b": 300, "c": 300},
+		},
+		{
+			name:  "单独限速低于平均值的主机让出剩余配额",
+			total: 900,
+			hosts: map[string]int64{"a": 100},
+			open:  []string{"a", "b", "c"},
+			want:  map[string]int64{"a": 100, "b": 400, "c": 400},
+		},
+		{
+			name:  "不限总速率时按单独限速",
+			hosts: map[string]int64{"a": 100},
+			open:  []string{"a", "b"},
+			want:  map[string]int64{"a": 100, "b": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.total, tt.hosts)
+			streams := make(map[string]*Stream)
+			for _, host := range tt.open {
+				streams[host] = l.Open(host)
+			}
+			for host, want := range tt.want {
+				if got := streams[host].rate(); got != want {
+					t.Errorf("主机 %s 分得的速率 = %d, want %d", host, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCloseReleasesShare(t *testing.T) {
+	l := New(1000, nil)
+	a := l.Open("a")
+	b := l.Open("b")
+	if got := a.rate(); got != 500 {
+		t.Fatalf("两个上传时分得的速率 = %d, want 500", got)
+	}
+	b.Close()
+	if got := a.rate(); got != 1000 {
+		t.Errorf("另一个上传结束后分得的速率 = %d, want 1000", got)
+	}
+}
+
+// 等待中调高限速时立即生效，不需要等按原速率计算的时间结束
+func TestWaitPicksUpUpdate(t *testing.T) {
+	l := New(100, nil)
+	s := l.Open("a")
+	defer s.Close()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		l.Update(1<<30, nil)
+	}()
+
+	start := time.Now()
+	s.Wait(1000) // 按 100 B/s 需要等待 10 秒
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("调高限速后等待了 %s，应立即生效", elapsed)
+	}
+}
+
+func TestWaitRate(t *testing.T) {
+	l := New(10000, nil)
+	s := l.Open("a")
+	defer s.Close()
+
+	start := time.Now()
+	for range 5 {
+		s.Wait(1000)
+	}
+	// 5000 字节按 10000 B/s 约需 0.5 秒
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("发送 5000 字节用时 %s，want 约 500ms", elapsed)
+	}
+}
+
+func TestNilStream(t *testing.T) {
+	var l *Limiter
+	s := l.Open("a")
+	s.Wait(1 << 20)
+	s.Close()
+}
@@ -0,0 +1,85 @@
+package compress
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩算法
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Extension 返回压缩后的文件扩展名
+func Extension(codec string) string {
+	switch codec {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ValidateLevel 检查压缩级别，0 表示使用默认级别
+func ValidateLevel(codec string, level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch codec {
+	case Gzip:
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return fmt.Errorf("gzip 压缩级别必须在 1-9 之间: %d", level)
+		}
+	case Zstd:
+		if level < 1 || level > 22 {
+			return fmt.Errorf("zstd 压缩级别必须在 1-22 之间: %d", level)
+		}
+	}
+	return nil
+}
+
+// NewReader 返回边读取边压缩的数据流，读取 src 并输出压缩后的数据
+// 关闭返回的读取端会让压缩协程退出
+func NewReader(src io.Reader, codec string, level int) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+
+	var enc io.WriteCloser
+	switch codec {
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		w, err := gzip.NewWriterLevel(pw, level)
+		if err != nil {
+			return nil, fmt.Errorf("创建 gzip 压缩器失败: %w", err)
+		}
+		enc = w
+	case Zstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		w, err := zstd.NewWriter(pw, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("创建 zstd 压缩器失败: %w", err)
+		}
+		enc = w
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", codec)
+	}
+
+	go func() {
+		_, err := io.Copy(enc, src)
+		if closeErr := enc.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
@@ -1,27 +1,49 @@
 package config
 
 import (
+	"dockship/internal/compress"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // Config 全局配置结构
 type Config struct {
-	Images        []ImageConfig  `mapstructure:"images"`         // 需要传输的镜像列表
-	TargetHosts   []string       `mapstructure:"target_hosts"`   // 目标主机列表
+	Images        []ImageConfig  `mapstructure:"-"`              // 需要传输的镜像列表（见 parseImages）
+	TargetHosts   []HostConfig   `mapstructure:"-"`              // 目标主机列表（见 parseTargetHosts）
+	HostGroups    []HostGroup    `mapstructure:"-"`              // 命名主机组（见 parseHostGroups）
+	Inventory     string         `mapstructure:"inventory"`      // Ansible 主机清单文件（INI/YAML）
+	ExcludeHosts  []string       `mapstructure:"exclude_hosts"`  // 从所有目标主机中排除的主机（支持范围和网段）
 	SSH           SSHConfig      `mapstructure:"ssh"`            // SSH连接配置
+	Become        BecomeConfig   `mapstructure:"become"`         // 远程 docker 命令的提权配置
 	LocalStorage  StorageConfig  `mapstructure:"local_storage"`  // 本地存储配置
 	RemoteStorage StorageConfig  `mapstructure:"remote_storage"` // 远程存储配置
 	Transfer      TransferConfig `mapstructure:"transfer"`       // 传输配置
 	Hooks         HooksConfig    `mapstructure:"hooks"`          // 全局Hooks配置
+
+	Targets    []Target        `mapstructure:"-"` // 解析后的目标主机连接参数
+	Excluded   []string        `mapstructure:"-"` // 被 exclude_hosts 排除的主机名称
+	Expansions []HostExpansion `mapstructure:"-"` // target_hosts 中范围和网段的展开结果
+	RelaySeed  *Target         `mapstructure:"-"` // 中继分发指定的种子主机（目标主机或中转主机），为 nil 时自动选择
+
+	ConfigFile string `mapstructure:"-"` // 配置文件路径，收到 SIGHUP 时重新读取
+
+	excludedAddresses []string // 被 exclude_hosts 排除的主机地址
 }
 
 // ImageConfig 镜像配置（支持纯字符串或带hooks的结构体）
 type ImageConfig struct {
 	Name  string      `mapstructure:"name"`  // 镜像名称
 	Hooks HooksConfig `mapstructure:"hooks"` // 镜像级Hooks配置
+
+	Groups       []string `mapstructure:"groups"`        // 目标主机组，为空且未指定 hosts 时发送到所有主机
+	Hosts        []string `mapstructure:"hosts"`         // 额外的目标主机
+	ExcludeHosts []string `mapstructure:"exclude_hosts"` // 排除的主机
 }
 
 // SSHConfig SSH连接配置
@@ -31,12 +53,65 @@ type SSHConfig struct {
 	KeyFile  string `mapstructure:"key_file"` // SSH私钥文件路径（推荐）
 	Port     int    `mapstructure:"port"`     // SSH端口
 	Timeout  int    `mapstructure:"timeout"`  // 连接超时时间（秒）
+
+	KeepaliveInterval int `mapstructure:"keepalive_interval"`  // 发送 keepalive 请求的间隔（秒），0 表示关闭
+	KeepaliveCountMax int `mapstructure:"keepalive_count_max"` // 连续多少次 keepalive 无响应后断开连接
+
+	KeyFiles       []string `mapstructure:"key_files"`    // 额外的SSH私钥文件，按顺序尝试
+	AuthMethods    []string `mapstructure:"auth_methods"` // 认证方式尝试顺序: agent/key/password/keyboard-interactive
+	AuthMethodsSet bool     `mapstructure:"-"`            // auth_methods 是否在配置文件中显式配置（而不是默认值）
+	CertFile       string   `mapstructure:"cert_file"`    // SSH用户证书，未配置时自动查找 <私钥>-cert.pub
+
+	PassphraseEnv  string `mapstructure:"passphrase_env"`  // 私钥口令所在的环境变量名
+	PassphraseFile string `mapstructure:"passphrase_file"` // 私钥口令文件路径
+
+	SSHConfigFile string `mapstructure:"ssh_config"` // OpenSSH 客户端配置文件（如 ~/.ssh/config），为空时不读取
+
+	JumpHosts     []JumpHostConfig `mapstructure:"jump_hosts"`      // 全局跳板链，按顺序逐跳连接
+	HostJumpHosts []HostJumpConfig `mapstructure:"host_jump_hosts"` // 指定主机使用的跳板链（覆盖全局）
+
+	HostKeyPolicy    string            `mapstructure:"host_key_policy"`   // 主机密钥校验策略: strict/accept-new/insecure
+	UserKnownHosts   []string          `mapstructure:"user_known_hosts"`  // 用户的 known_hosts 文件（支持哈希条目）
+	KnownHostsFile   string            `mapstructure:"known_hosts_file"`  // dockship 管理的 known_hosts 文件（accept-new 时写入）
+	HostFingerprints []HostFingerprint `mapstructure:"host_fingerprints"` // 按主机固定的主机密钥指纹
+}
+
+// JumpHostConfig 跳板机配置，未配置 user/pwd/key_file 时沿用全局SSH配置
+type JumpHostConfig struct {
+	Address  string `mapstructure:"address"`   // 跳板机地址
+	Port     int    `mapstructure:"port"`      // SSH端口，默认22
+	User     string `mapstructure:"user"`      // SSH用户名
+	Password string `mapstructure:"pwd"`       // SSH密码
+	KeyFile  string `mapstructure:"key_file"`  // SSH私钥文件路径
+	CertFile string `mapstructure:"cert_file"` // SSH用户证书
+}
+
+// HostJumpConfig 为指定主机配置的跳板链
+type HostJumpConfig struct {
+	Hosts     []string         `mapstructure:"hosts"`      // 目标主机列表
+	JumpHosts []JumpHostConfig `mapstructure:"jump_hosts"` // 跳板链
+}
+
+// HostFingerprint 固定的主机密钥指纹
+type HostFingerprint struct {
+	Host        string `mapstructure:"host"`        // 主机地址（可带端口）
+	Fingerprint string `mapstructure:"fingerprint"` // 指纹，如 SHA256:xxxx
+}
+
+// BecomeConfig 提权配置（用于不在 docker 组中的SSH用户）
+type BecomeConfig struct {
+	Method      string `mapstructure:"method"`       // 提权方式: sudo/su，为空时不提权
+	User        string `mapstructure:"user"`         // 提权后的用户，默认 root
+	Password    string `mapstructure:"pwd"`          // 提权密码，通过标准输入发送，不会出现在命令行
+	PasswordEnv string `mapstructure:"password_env"` // 提权密码所在的环境变量名
+	Hooks       bool   `mapstructure:"hooks"`        // hooks 是否也提权执行
 }
 
 // StorageConfig 本地存储配置
 type StorageConfig struct {
 	TempDir     string `mapstructure:"temp_dir"`     // 本地临时文件目录
 	AutoCleanup bool   `mapstructure:"auto_cleanup"` // 传输完成后是否自动清理本地临时文件
+	CacheDir    string `mapstructure:"cache_dir"`    // 二进制增量的缓存目录（远程：上一次传输的 tar 文件；本地：其签名）
 }
 
 // TransferConfig 传输配置
@@ -45,6 +120,38 @@ type TransferConfig struct {
 	Retry      int  `mapstructure:"retry"`      // 失败重试次数
 	AutoLoad   bool `mapstructure:"auto_load"`  // 是否在远程主机自动加载镜像
 	Confirm    bool `mapstructure:"confirm"`    // 执行前是否需要二次确认
+
+	StallTimeout int    `mapstructure:"stall_timeout"` // 上传无进展超过该秒数时中断并重试，0 表示关闭
+	UploadMethod string `mapstructure:"upload_method"` // 上传方式: auto/sftp/scp/cat
+	Mode         string `mapstructure:"mode"`          // 传输模式: file（先保存再上传）/stream（docker save 直接管道到远程 docker load）
+
+	Multicast       bool `mapstructure:"multicast"`         // 批量分发：同一批主机共享一次本地读取
+	MulticastBuffer int  `mapstructure:"multicast_buffer"`  // 批量分发时每台主机的缓冲区大小（MB）
+	SlowHostTimeout int  `mapstructure:"slow_host_timeout"` // 主机缓冲区写满超过该秒数时脱离批量分发，随后单独传输
+
+	Compression      string `mapstructure:"compression"`       // 传输压缩: none/gzip/zstd
+	CompressionLevel int    `mapstructure:"compression_level"` // 压缩级别，0 表示默认级别
+
+	VerifyChecksum bool `mapstructure:"verify_checksum"` // 加载前校验远程文件的 SHA-256（file 模式）
+	Force          bool `mapstructure:"force"`           // 远程已有相同的镜像时仍然传输（--force）
+	LayerDelta     bool `mapstructure:"layer_delta"`     // 层级增量传输：只发送远程缺少的镜像层（file 模式）
+	BinaryDelta    bool `mapstructure:"binary_delta"`    // 二进制增量传输：只发送与远程缓存中上一版本 tar 文件的差异（file 模式）
+	DeltaHelper    bool `mapstructure:"delta_helper"`    // 二进制增量时将 dockship 自身上传到远程作为辅助程序（计算签名和重建）
+
+	BandwidthLimit string `mapstructure:"bandwidth_limit"` // 上传总限速（如 10MB、100Mbit），由进行中的上传平分，为空表示不限
+	BandwidthRate  int64  `mapstructure:"-"`               // 解析后的上传总限速（字节/秒），0 表示不限
+
+	Relay RelayConfig `mapstructure:"relay"` // 中继分发：只向种子主机上传一次，再由主机之间逐级转发
+}
+
+// RelayConfig 中继分发配置
+// 本机只向种子主机上传，已收到镜像的主机通过 ssh 转发给其他主机，按 fanout 组成分发树
+type RelayConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`     // 是否开启中继分发（file 模式）
+	Seed       string   `mapstructure:"seed"`        // 种子主机：目标主机名称/地址，或只负责转发的中转主机（[user@]host[:port]）；为空时使用第一台目标主机
+	Fanout     int      `mapstructure:"fanout"`      // 每台主机同时转发的主机数
+	KeyFile    string   `mapstructure:"key_file"`    // 主机之间转发时使用的私钥（转发主机上的路径），为空时使用转发主机上 ssh 的默认配置
+	SSHOptions []string `mapstructure:"ssh_options"` // 转发时 ssh 的额外选项（-o），如 StrictHostKeyChecking=yes
 }
 
 // HooksConfig Hooks配置
@@ -68,7 +175,7 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
-	// 解析配置（images 需要特殊处理以兼容纯字符串和结构体两种写法）
+	// 解析配置（images 和 target_hosts 需要特殊处理以兼容纯字符串和结构体两种写法）
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
@@ -79,11 +186,47 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("解析镜像配置失败: %w", err)
 	}
 
+	// 手动解析target_hosts字段，兼容两种YAML写法
+	if err := parseTargetHosts(&cfg); err != nil {
+		return nil, fmt.Errorf("解析目标主机配置失败: %w", err)
+	}
+
+	// 解析主机组，组内新出现的主机会加入目标主机列表
+	if err := parseHostGroups(&cfg); err != nil {
+		return nil, fmt.Errorf("解析主机组配置失败: %w", err)
+	}
+
+	// 从 Ansible 主机清单加载目标主机和主机组
+	if err := loadInventory(&cfg); err != nil {
+		return nil, fmt.Errorf("加载主机清单失败: %w", err)
+	}
+
+	// 排除 exclude_hosts 中的主机
+	if err := cfg.excludeHosts(); err != nil {
+		return nil, fmt.Errorf("解析 exclude_hosts 失败: %w", err)
+	}
+
+	// 展开配置中的 ~ 路径
+	cfg.expandPaths()
+
+	rate, err := ParseBandwidth(cfg.Transfer.BandwidthLimit)
+	if err != nil {
+		return nil, fmt.Errorf("transfer.bandwidth_limit 无效: %w", err)
+	}
+	cfg.Transfer.BandwidthRate = rate
+	cfg.SSH.AuthMethodsSet = viper.InConfig("ssh.auth_methods")
+
+	// 解析目标主机的连接参数（ssh_config 别名等）
+	if err := cfg.resolveTargets(); err != nil {
+		return nil, fmt.Errorf("解析目标主机失败: %w", err)
+	}
+
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %w", err)
 	}
 
+	cfg.ConfigFile = configPath
 	globalConfig = &cfg
 	return &cfg, nil
 }
@@ -132,24 +275,74 @@ func parseImages(cfg *Config) error {
 					}
 				}
 			}
+			// 目标主机选择:
+			//   groups: [web]
+			//   hosts: [192.168.1.10]
+			//   exclude_hosts: [192.168.1.11]
+			imgCfg.Groups = stringList(v["groups"])
+			hosts, err := expandHostList(stringList(v["hosts"]))
+			if err != nil {
+				return fmt.Errorf("镜像 %s: %w", imgCfg.Name, err)
+			}
+			imgCfg.Hosts = hosts
+			excludeHosts, err := expandHostList(stringList(v["exclude_hosts"]))
+			if err != nil {
+				return fmt.Errorf("镜像 %s: %w", imgCfg.Name, err)
+			}
+			imgCfg.ExcludeHosts = excludeHosts
 			cfg.Images = append(cfg.Images, imgCfg)
 		}
 	}
 	return nil
 }
 
+// stringList 将单个字符串或字符串列表转换为 []string
+func stringList(raw interface{}) []string {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
 // setDefaults 设置默认配置值
 func setDefaults() {
 	viper.SetDefault("ssh.port", 22)
 	viper.SetDefault("ssh.timeout", 30)
+	viper.SetDefault("ssh.keepalive_interval", 15)
+	viper.SetDefault("ssh.keepalive_count_max", 3)
+	viper.SetDefault("ssh.auth_methods", []string{"agent", "key", "password", "keyboard-interactive"})
+	viper.SetDefault("ssh.host_key_policy", "accept-new")
+	viper.SetDefault("ssh.user_known_hosts", []string{"~/.ssh/known_hosts"})
+	viper.SetDefault("ssh.known_hosts_file", "~/.dockship/known_hosts")
+	viper.SetDefault("become.user", "root")
 	viper.SetDefault("local_storage.temp_dir", "/tmp/dockship")
 	viper.SetDefault("local_storage.auto_cleanup", true)
+	viper.SetDefault("local_storage.cache_dir", "~/.dockship/cache")
 	viper.SetDefault("remote_storage.temp_dir", "/tmp")
 	viper.SetDefault("remote_storage.auto_cleanup", true)
+	viper.SetDefault("remote_storage.cache_dir", "/var/tmp/dockship")
 	viper.SetDefault("transfer.concurrent", 5)
 	viper.SetDefault("transfer.retry", 3)
 	viper.SetDefault("transfer.auto_load", true)
 	viper.SetDefault("transfer.confirm", true)
+	viper.SetDefault("transfer.stall_timeout", 60)
+	viper.SetDefault("transfer.upload_method", "auto")
+	viper.SetDefault("transfer.mode", "file")
+	viper.SetDefault("transfer.multicast", false)
+	viper.SetDefault("transfer.multicast_buffer", 32)
+	viper.SetDefault("transfer.slow_host_timeout", 10)
+	viper.SetDefault("transfer.compression", "none")
+	viper.SetDefault("transfer.verify_checksum", true)
+	viper.SetDefault("transfer.relay.fanout", 2)
 }
 
 // Validate 验证配置的有效性
@@ -159,22 +352,36 @@ func (c *Config) Validate() error {
 	}
 
 	if len(c.TargetHosts) == 0 {
-		return fmt.Errorf("目标主机列表不能为空")
+		if len(c.Excluded) > 0 {
+			return fmt.Errorf("目标主机列表为空（展开后的 %d 台主机均被 exclude_hosts 排除）", len(c.Excluded))
+		}
+		return fmt.Errorf("目标主机列表不能为空（target_hosts、host_groups 或 inventory）")
 	}
 
-	if c.SSH.User == "" {
-		return fmt.Errorf("SSH用户名不能为空")
+	if err := c.validateDuplicateTargets(); err != nil {
+		return err
 	}
 
-	// 必须提供密码或密钥文件之一
-	if c.SSH.Password == "" && c.SSH.KeyFile == "" {
-		return fmt.Errorf("必须提供SSH密码或密钥文件")
+	if err := c.validateImageTargets(); err != nil {
+		return err
 	}
 
-	// 如果指定了密钥文件，检查文件是否存在
-	if c.SSH.KeyFile != "" {
-		if _, err := os.Stat(c.SSH.KeyFile); err != nil {
-			return fmt.Errorf("SSH密钥文件不存在: %s", c.SSH.KeyFile)
+	for _, target := range c.Targets {
+		if target.User == "" {
+			return fmt.Errorf("主机 %s 的SSH用户名不能为空（ssh.user 或 ssh_config 中的 User）", target.Name)
+		}
+		if target.Port <= 0 || target.Port > 65535 {
+			return fmt.Errorf("主机 %s 的SSH端口无效: %d", target.Name, target.Port)
+		}
+		// 至少要有一种可用的认证方式：ssh-agent、密钥文件或密码
+		if err := c.SSH.validateAuth(target.KeyFiles, target.CertFiles, target.Password); err != nil {
+			return fmt.Errorf("主机 %s: %w", target.Name, err)
+		}
+		if err := c.SSH.validateJumpHosts(target.JumpHosts); err != nil {
+			return fmt.Errorf("主机 %s: %w", target.Name, err)
+		}
+		if err := target.Become.validate(); err != nil {
+			return fmt.Errorf("主机 %s: %w", target.Name, err)
 		}
 	}
 
@@ -182,14 +389,425 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("SSH端口无效: %d", c.SSH.Port)
 	}
 
+	if c.SSH.KeepaliveInterval < 0 {
+		return fmt.Errorf("ssh.keepalive_interval 不能为负数: %d", c.SSH.KeepaliveInterval)
+	}
+	if c.SSH.KeepaliveInterval > 0 && c.SSH.KeepaliveCountMax <= 0 {
+		return fmt.Errorf("ssh.keepalive_count_max 必须大于 0: %d", c.SSH.KeepaliveCountMax)
+	}
+
+	switch c.SSH.HostKeyPolicy {
+	case "strict", "accept-new", "insecure":
+	default:
+		return fmt.Errorf("SSH主机密钥校验策略无效: %s（可选 strict/accept-new/insecure）", c.SSH.HostKeyPolicy)
+	}
+
+	if err := c.SSH.validateJumpHosts(c.SSH.JumpHosts); err != nil {
+		return err
+	}
+	for _, rule := range c.SSH.HostJumpHosts {
+		if len(rule.Hosts) == 0 {
+			return fmt.Errorf("host_jump_hosts 条目必须提供 hosts")
+		}
+		if err := c.SSH.validateJumpHosts(rule.JumpHosts); err != nil {
+			return err
+		}
+	}
+
+	for _, fp := range c.SSH.HostFingerprints {
+		if fp.Host == "" || fp.Fingerprint == "" {
+			return fmt.Errorf("host_fingerprints 条目必须同时提供 host 和 fingerprint")
+		}
+	}
+
 	if c.Transfer.Concurrent <= 0 {
 		c.Transfer.Concurrent = 1
 	}
+	if c.Transfer.StallTimeout < 0 {
+		return fmt.Errorf("transfer.stall_timeout 不能为负数: %d", c.Transfer.StallTimeout)
+	}
+	switch c.Transfer.UploadMethod {
+	case "auto", "sftp", "scp", "cat":
+	default:
+		return fmt.Errorf("上传方式无效: %s（可选 auto/sftp/scp/cat）", c.Transfer.UploadMethod)
+	}
+	if c.Transfer.Multicast {
+		if c.Transfer.MulticastBuffer <= 0 {
+			return fmt.Errorf("transfer.multicast_buffer 必须大于 0: %d", c.Transfer.MulticastBuffer)
+		}
+		if c.Transfer.SlowHostTimeout <= 0 {
+			return fmt.Errorf("transfer.slow_host_timeout 必须大于 0: %d", c.Transfer.SlowHostTimeout)
+		}
+		// 等待慢主机期间其他主机没有数据可写，等待时间不能达到停滞超时，否则正常的主机也会被判定为停滞
+		if c.Transfer.StallTimeout > 0 && c.Transfer.SlowHostTimeout >= c.Transfer.StallTimeout {
+			return fmt.Errorf("transfer.slow_host_timeout（%d 秒）必须小于 stall_timeout（%d 秒）", c.Transfer.SlowHostTimeout, c.Transfer.StallTimeout)
+		}
+	}
+	switch c.Transfer.Mode {
+	case "file":
+	case "stream":
+		// 流式传输直接导入镜像，不会在远程留下 tar 文件
+		if !c.Transfer.AutoLoad {
+			return fmt.Errorf("transfer.mode 为 stream 时镜像直接导入远程 docker，需要 auto_load: true")
+		}
+		for _, target := range c.Targets {
+			if target.Become.Method == "su" {
+				return fmt.Errorf("主机 %s: stream 模式不支持 su 提权（su 需要伪终端，无法传输二进制数据），请改用 sudo 或 file 模式", target.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("传输模式无效: %s（可选 file/stream）", c.Transfer.Mode)
+	}
+	switch c.Transfer.Compression {
+	case compress.None, compress.Gzip, compress.Zstd:
+	default:
+		return fmt.Errorf("压缩算法无效: %s（可选 none/gzip/zstd）", c.Transfer.Compression)
+	}
+	if err := compress.ValidateLevel(c.Transfer.Compression, c.Transfer.CompressionLevel); err != nil {
+		return err
+	}
+	if c.Transfer.LayerDelta && (c.Transfer.Mode != "file" || !c.Transfer.AutoLoad) {
+		return fmt.Errorf("transfer.layer_delta 需要 file 模式和 auto_load: true（精简归档只能由远程 docker load 补全）")
+	}
+	if c.Transfer.BinaryDelta && c.Transfer.Mode != "file" {
+		return fmt.Errorf("transfer.binary_delta 需要 file 模式（远程需要保存上一次传输的 tar 文件）")
+	}
+	if c.Transfer.Relay.Enabled {
+		if c.Transfer.Mode != "file" {
+			return fmt.Errorf("transfer.relay 需要 file 模式（镜像文件保存在主机上才能继续转发）")
+		}
+		if c.Transfer.Relay.Fanout <= 0 {
+			return fmt.Errorf("transfer.relay.fanout 必须大于 0: %d", c.Transfer.Relay.Fanout)
+		}
+		if seed := c.RelaySeed; seed != nil {
+			if seed.User == "" {
+				return fmt.Errorf("种子主机 %s 的SSH用户名不能为空", seed.Name)
+			}
+			if err := c.SSH.validateAuth(seed.KeyFiles, seed.CertFiles, seed.Password); err != nil {
+				return fmt.Errorf("种子主机 %s: %w", seed.Name, err)
+			}
+		}
+	}
+	// 压缩后的大小事先未知，而 SCP 协议需要先声明文件大小
+	if c.Transfer.Compression != compress.None && c.Transfer.Mode == "file" && c.Transfer.UploadMethod == "scp" {
+		return fmt.Errorf("upload_method 为 scp 时不支持压缩（SCP 需要预先知道文件大小），请改用 auto/sftp/cat")
+	}
+
+	return nil
+}
+
+// validate 验证提权配置
+func (b *BecomeConfig) validate() error {
+	if b.Method == "" {
+		return nil
+	}
+	if b.PasswordEnv != "" && b.Password == "" {
+		if _, ok := os.LookupEnv(b.PasswordEnv); !ok {
+			return fmt.Errorf("提权密码环境变量 %s 未设置", b.PasswordEnv)
+		}
+	}
+
+	switch b.Method {
+	case "sudo":
+	case "su":
+		if b.Password == "" {
+			return fmt.Errorf("become.method 为 su 时必须提供提权密码（become.pwd 或 become.password_env）")
+		}
+	default:
+		return fmt.Errorf("提权方式无效: %s（可选 sudo/su）", b.Method)
+	}
+	if b.User == "" {
+		return fmt.Errorf("提权用户 become.user 不能为空")
+	}
+	return nil
+}
+
+// merge 合并按主机配置的提权参数，method 为 none 时该主机不提权
+func (b BecomeConfig) merge(host *BecomeConfig) BecomeConfig {
+	if host == nil {
+		return b
+	}
+	if host.Method == "none" {
+		return BecomeConfig{}
+	}
+	if host.Method != "" {
+		b.Method = host.Method
+	}
+	if host.User != "" {
+		b.User = host.User
+	}
+	if host.Password != "" || host.PasswordEnv != "" {
+		b.Password, b.PasswordEnv = host.Password, host.PasswordEnv
+	}
+	b.Hooks = b.Hooks || host.Hooks
+	return b
+}
+
+// resolvePassword 从环境变量读取提权密码
+func (b *BecomeConfig) resolvePassword() {
+	if b.Password == "" && b.PasswordEnv != "" {
+		b.Password = os.Getenv(b.PasswordEnv)
+	}
+}
+
+// validateAuth 验证SSH认证配置
+func (s *SSHConfig) validateAuth(keyFiles, certFiles []string, password string) error {
+	if len(s.AuthMethods) == 0 {
+		return fmt.Errorf("SSH认证方式列表不能为空")
+	}
+
+	usable := false
+	for _, method := range s.AuthMethods {
+		switch method {
+		case "agent":
+			if os.Getenv("SSH_AUTH_SOCK") != "" {
+				usable = true
+			}
+		case "key":
+			if len(keyFiles) > 0 {
+				usable = true
+			}
+		case "password":
+			if password != "" {
+				usable = true
+			}
+		case "keyboard-interactive":
+			// 密码提示可以自动应答；其他提示（如 OTP）需要在终端输入，只有显式配置了 auth_methods 时才算作可用，
+			// 否则默认列表中的键盘交互会让没有配置任何凭据的情况也通过检查
+			if password != "" || s.AuthMethodsSet && term.IsTerminal(int(os.Stdin.Fd())) {
+				usable = true
+			}
+		default:
+			return fmt.Errorf("不支持的SSH认证方式: %s（可选 agent/key/password/keyboard-interactive）", method)
+		}
+	}
+	if !usable {
+		return fmt.Errorf("必须提供SSH密码、密钥文件或可用的 ssh-agent（SSH_AUTH_SOCK），或在 ssh.auth_methods 中显式配置 keyboard-interactive")
+	}
+
+	// 检查密钥文件是否存在
+	for _, keyFile := range keyFiles {
+		if _, err := os.Stat(keyFile); err != nil {
+			return fmt.Errorf("SSH密钥文件不存在: %s", keyFile)
+		}
+	}
+	for _, certFile := range certFiles {
+		if _, err := os.Stat(certFile); err != nil {
+			return fmt.Errorf("SSH证书文件不存在: %s", certFile)
+		}
+	}
+	return nil
+}
 
+// validateJumpHosts 验证跳板链配置
+func (s *SSHConfig) validateJumpHosts(hops []JumpHostConfig) error {
+	for _, hop := range hops {
+		if hop.Address == "" {
+			return fmt.Errorf("跳板机地址不能为空")
+		}
+		if hop.Port < 0 || hop.Port > 65535 {
+			return fmt.Errorf("跳板机 %s 的SSH端口无效: %d", hop.Address, hop.Port)
+		}
+		if hop.KeyFile != "" {
+			if _, err := os.Stat(hop.KeyFile); err != nil {
+				return fmt.Errorf("跳板机 %s 的SSH密钥文件不存在: %s", hop.Address, hop.KeyFile)
+			}
+		}
+		if hop.CertFile != "" {
+			if _, err := os.Stat(hop.CertFile); err != nil {
+				return fmt.Errorf("跳板机 %s 的SSH证书文件不存在: %s", hop.Address, hop.CertFile)
+			}
+		}
+	}
 	return nil
 }
 
+// hostJumpHosts 返回 host_jump_hosts 中为指定主机配置的跳板链
+func (s *SSHConfig) hostJumpHosts(host string) ([]JumpHostConfig, bool) {
+	for _, rule := range s.HostJumpHosts {
+		for _, h := range rule.Hosts {
+			if hostRef(h) == host {
+				return rule.JumpHosts, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// AllKeyFiles 返回 key_file 与 key_files 合并后的私钥文件列表
+func (s *SSHConfig) AllKeyFiles() []string {
+	var files []string
+	if s.KeyFile != "" {
+		files = append(files, s.KeyFile)
+	}
+	return append(files, s.KeyFiles...)
+}
+
+// CertFiles 返回全局配置的SSH用户证书
+func (s *SSHConfig) CertFiles() []string {
+	if s.CertFile == "" {
+		return nil
+	}
+	return []string{s.CertFile}
+}
+
+// expandPaths 展开配置中以 ~ 开头的路径
+func (c *Config) expandPaths() {
+	c.SSH.KeyFile = ExpandPath(c.SSH.KeyFile)
+	c.SSH.CertFile = ExpandPath(c.SSH.CertFile)
+	c.SSH.KnownHostsFile = ExpandPath(c.SSH.KnownHostsFile)
+	c.SSH.PassphraseFile = ExpandPath(c.SSH.PassphraseFile)
+	c.SSH.SSHConfigFile = ExpandPath(c.SSH.SSHConfigFile)
+	for i, file := range c.SSH.KeyFiles {
+		c.SSH.KeyFiles[i] = ExpandPath(file)
+	}
+	for i := range c.SSH.JumpHosts {
+		c.SSH.JumpHosts[i].KeyFile = ExpandPath(c.SSH.JumpHosts[i].KeyFile)
+		c.SSH.JumpHosts[i].CertFile = ExpandPath(c.SSH.JumpHosts[i].CertFile)
+	}
+	for i := range c.TargetHosts {
+		host := &c.TargetHosts[i]
+		host.KeyFile = ExpandPath(host.KeyFile)
+		host.CertFile = ExpandPath(host.CertFile)
+		for j := range host.JumpHosts {
+			host.JumpHosts[j].KeyFile = ExpandPath(host.JumpHosts[j].KeyFile)
+			host.JumpHosts[j].CertFile = ExpandPath(host.JumpHosts[j].CertFile)
+		}
+	}
+	for i := range c.SSH.HostJumpHosts {
+		for j := range c.SSH.HostJumpHosts[i].JumpHosts {
+			hop := &c.SSH.HostJumpHosts[i].JumpHosts[j]
+			hop.KeyFile = ExpandPath(hop.KeyFile)
+			hop.CertFile = ExpandPath(hop.CertFile)
+		}
+	}
+	for i, file := range c.SSH.UserKnownHosts {
+		c.SSH.UserKnownHosts[i] = ExpandPath(file)
+	}
+	c.LocalStorage.CacheDir = ExpandPath(c.LocalStorage.CacheDir)
+}
+
+// ExpandPath 将路径开头的 ~ 展开为用户主目录
+func ExpandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// PinnedFingerprints 返回按主机分组的固定指纹
+func (s *SSHConfig) PinnedFingerprints() map[string][]string {
+	pinned := make(map[string][]string)
+	for _, fp := range s.HostFingerprints {
+		pinned[fp.Host] = append(pinned[fp.Host], fp.Fingerprint)
+	}
+	return pinned
+}
+
 // GetConfig 获取全局配置实例
 func GetConfig() *Config {
 	return globalConfig
 }
+
+// bandwidthUnits 限速单位：字节单位按 1024 进位，比特单位按 1000 进位
+var bandwidthUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"gbit", 1e9 / 8}, {"mbit", 1e6 / 8}, {"kbit", 1e3 / 8},
+	{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// ParseBandwidth 解析限速，如 10MB、512KB/s、100Mbit，不带单位时为字节/秒
+// 返回字节/秒，空字符串或 0 表示不限速
+func ParseBandwidth(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimSpace(strings.TrimSuffix(v, "/s"))
+	if v == "" {
+		return 0, nil
+	}
+	unit := 1.0
+	for _, u := range bandwidthUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v, unit = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无法解析限速 %q（示例: 10MB、512KB、100Mbit）", s)
+	}
+	rate := int64(n * unit)
+	if n > 0 && rate == 0 {
+		rate = 1
+	}
+	return rate, nil
+}
+
+// FormatBandwidth 格式化限速（字节/秒），0 表示不限
+func FormatBandwidth(rate int64) string {
+	switch {
+	case rate <= 0:
+		return "不限"
+	case rate >= 1<<30:
+		return fmt.Sprintf("%.2f GB/s", float64(rate)/(1<<30))
+	case rate >= 1<<20:
+		return fmt.Sprintf("%.2f MB/s", float64(rate)/(1<<20))
+	case rate >= 1<<10:
+		return fmt.Sprintf("%.2f KB/s", float64(rate)/(1<<10))
+	}
+	return fmt.Sprintf("%d B/s", rate)
+}
+
+// LoadBandwidthLimits 只读取配置文件中的上传限速：transfer.bandwidth_limit 和主机条目（target_hosts、host_groups）的 bandwidth_limit
+// 使用独立的 viper 实例，不重新解析其他配置，也不影响已加载的全局配置；用于运行中收到 SIGHUP 时更新限速
+func LoadBandwidthLimits(configPath string) (int64, map[string]int64, error) {
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return 0, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	total, err := ParseBandwidth(v.GetString("transfer.bandwidth_limit"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("transfer.bandwidth_limit 无效: %w", err)
+	}
+
+	// 主机条目的写法与 target_hosts 相同，组内的主机也可以带连接参数
+	entries, _ := v.Get("target_hosts").([]interface{})
+	if groups, ok := v.Get("host_groups").(map[string]interface{}); ok {
+		for _, members := range groups {
+			if members, ok := members.([]interface{}); ok {
+				entries = append(entries, members...)
+			}
+		}
+	}
+
+	hosts := make(map[string]int64)
+	for _, item := range entries {
+		if _, ok := item.(map[string]interface{}); !ok {
+			continue
+		}
+		parsed, err := parseHostEntry(item)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, host := range parsed {
+			rate, err := ParseBandwidth(host.BandwidthLimit)
+			if err != nil {
+				return 0, nil, fmt.Errorf("主机 %s 的 bandwidth_limit 无效: %w", host.Name, err)
+			}
+			if rate > 0 {
+				hosts[host.Name] = rate
+			}
+		}
+	}
+	return total, hosts, nil
+}
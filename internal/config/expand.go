@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"dockship/internal/hostpattern"
+)
+
+// HostExpansion target_hosts 中一个范围或网段的展开结果
+type HostExpansion struct {
+	Pattern string // 配置中的写法，如 10.0.3.[10:49]
+	Count   int    // 展开后的主机数（排除之前）
+}
+
+// ExpansionSummary 返回各范围和网段展开后的主机数，如 "10.0.3.[10:49] → 40 台"，没有范围和网段时返回空字符串
+func (c *Config) ExpansionSummary() string {
+	parts := make([]string, 0, len(c.Expansions))
+	for _, e := range c.Expansions {
+		parts = append(parts, fmt.Sprintf("%s → %d 台", e.Pattern, e.Count))
+	}
+	return strings.Join(parts, "，")
+}
+
+// expandHostList 展开主机列表中的范围和网段
+func expandHostList(patterns []string) ([]string, error) {
+	var hosts []string
+	for _, pattern := range patterns {
+		expanded, err := hostpattern.Expand(pattern)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+	}
+	return hosts, nil
+}
+
+// excludeHosts 从目标主机和主机组中去掉 exclude_hosts 匹配的主机
+// 主机名称或地址任一匹配即排除
+func (c *Config) excludeHosts() error {
+	if len(c.ExcludeHosts) == 0 {
+		return nil
+	}
+
+	patterns, err := expandHostList(c.ExcludeHosts)
+	if err != nil {
+		return err
+	}
+	excluded := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		excluded[hostRef(pattern)] = true
+	}
+
+	kept := c.TargetHosts[:0]
+	removed := make(map[string]bool)
+	for _, host := range c.TargetHosts {
+		if excluded[host.Name] || excluded[host.Address] {
+			removed[host.Name] = true
+			c.Excluded = append(c.Excluded, host.Name)
+			c.excludedAddresses = append(c.excludedAddresses, host.Address)
+			continue
+		}
+		kept = append(kept, host)
+	}
+	c.TargetHosts = kept
+
+	for i := range c.HostGroups {
+		group := &c.HostGroups[i]
+		hosts := group.Hosts[:0]
+		for _, host := range group.Hosts {
+			if !removed[host] {
+				hosts = append(hosts, host)
+			}
+		}
+		group.Hosts = hosts
+	}
+	return nil
+}
+
+// validateDuplicateTargets 检查展开后的目标主机是否重复（同名或指向同一地址和端口）
+func (c *Config) validateDuplicateTargets() error {
+	names := make(map[string]bool, len(c.TargetHosts))
+	for _, host := range c.TargetHosts {
+		if names[host.Name] {
+			return fmt.Errorf("目标主机重复: %s（展开后共 %d 台%s，请检查范围或网段是否重叠）", host.Name, len(c.TargetHosts), c.expansionDetail())
+		}
+		names[host.Name] = true
+	}
+
+	addresses := make(map[string]string, len(c.Targets))
+	for _, target := range c.Targets {
+		addr := net.JoinHostPort(target.Address, strconv.Itoa(target.Port))
+		if other, ok := addresses[addr]; ok {
+			return fmt.Errorf("目标主机 %s 与 %s 指向同一地址 %s（展开后共 %d 台%s）", target.Name, other, addr, len(c.Targets), c.expansionDetail())
+		}
+		addresses[addr] = target.Name
+	}
+	return nil
+}
+
+// expansionDetail 错误信息中附加的展开结果
+func (c *Config) expansionDetail() string {
+	if summary := c.ExpansionSummary(); summary != "" {
+		return ": " + summary
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// HostGroup 命名主机组
+type HostGroup struct {
+	Name  string   // 组名（小写）
+	Hosts []string // 组内主机名称
+}
+
+// parseHostGroups 手动解析host_groups字段
+//
+//	host_groups:
+//	  web:
+//	    - 192.168.1.10
+//	    - address: 192.168.1.11
+//	      port: 2222
+//	    - 10.0.3.[10:19]
+//
+// 组内主机与 target_hosts 写法相同；同名主机只会出现一次，未在 target_hosts 中出现的主机会被追加
+func parseHostGroups(cfg *Config) error {
+	groupsRaw := viper.Get("host_groups")
+	if groupsRaw == nil {
+		return nil
+	}
+
+	groupsMap, ok := groupsRaw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("host_groups 必须是 组名: 主机列表 的映射")
+	}
+
+	// 按组名排序，保证追加主机的顺序稳定
+	names := make([]string, 0, len(groupsMap))
+	for name := range groupsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		members, ok := groupsMap[name].([]interface{})
+		if !ok {
+			return fmt.Errorf("主机组 %s 必须是主机列表", name)
+		}
+
+		group := HostGroup{Name: strings.ToLower(name)}
+		for i, item := range members {
+			hosts, err := parseHostEntry(item)
+			if err != nil {
+				return fmt.Errorf("host_groups.%s[%d]: %w", name, i, err)
+			}
+			for _, host := range hosts {
+				if err := cfg.addTargetHost(host, item); err != nil {
+					return fmt.Errorf("host_groups.%s[%d]: %w", name, i, err)
+				}
+				if !containsString(group.Hosts, host.Name) {
+					group.Hosts = append(group.Hosts, host.Name)
+				}
+			}
+		}
+		cfg.HostGroups = append(cfg.HostGroups, group)
+	}
+	return nil
+}
+
+// addTargetHost 将主机加入目标主机列表，已存在同名主机时只允许以纯字符串引用
+func (c *Config) addTargetHost(host HostConfig, raw interface{}) error {
+	if !c.hasTargetHost(host.Name) {
+		c.TargetHosts = append(c.TargetHosts, host)
+		return nil
+	}
+	if _, isRef := raw.(string); isRef {
+		return nil
+	}
+	return fmt.Errorf("主机 %s 重复定义，请在 target_hosts 中定义连接参数，组内只写主机名", host.Name)
+}
+
+// group 按名称查找主机组（不区分大小写）
+func (c *Config) group(name string) (*HostGroup, bool) {
+	name = strings.ToLower(name)
+	for i := range c.HostGroups {
+		if c.HostGroups[i].Name == name {
+			return &c.HostGroups[i], true
+		}
+	}
+	return nil, false
+}
+
+// TargetsFor 返回镜像需要分发的目标主机
+// 未指定 groups 和 hosts 时为全部主机，之后再去掉 exclude_hosts 中的主机
+// hosts 和 exclude_hosts 可以写主机名称，也可以写主机地址；带用户名的写法按主机名称匹配
+func (c *Config) TargetsFor(img ImageConfig) []Target {
+	selected := make(map[string]bool)
+	all := len(img.Groups) == 0 && len(img.Hosts) == 0
+
+	for _, name := range img.Groups {
+		if group, ok := c.group(name); ok {
+			for _, host := range group.Hosts {
+				selected[host] = true
+			}
+		}
+	}
+	for _, host := range img.Hosts {
+		selected[hostRef(host)] = true
+	}
+
+	excluded := make(map[string]bool, len(img.ExcludeHosts))
+	for _, host := range img.ExcludeHosts {
+		excluded[hostRef(host)] = true
+	}
+
+	targets := make([]Target, 0, len(c.Targets))
+	for _, target := range c.Targets {
+		if target.in(excluded) {
+			continue
+		}
+		if all || target.in(selected) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// validateImageTargets 验证镜像引用的主机组和主机都存在，且至少匹配一台主机
+func (c *Config) validateImageTargets() error {
+	known := make(map[string]bool, len(c.Targets))
+	for _, target := range c.Targets {
+		known[target.Name] = true
+		known[target.Address] = true
+	}
+	// 被全局 exclude_hosts 排除的主机仍可以在镜像中引用，不会被选中
+	for _, name := range c.Excluded {
+		known[name] = true
+	}
+	for _, address := range c.excludedAddresses {
+		known[address] = true
+	}
+
+	for _, img := range c.Images {
+		for _, name := range img.Groups {
+			if _, ok := c.group(name); !ok {
+				return fmt.Errorf("镜像 %s 引用的主机组不存在: %s", img.Name, name)
+			}
+		}
+		for _, host := range append(append([]string{}, img.Hosts...), img.ExcludeHosts...) {
+			if !known[hostRef(host)] {
+				return fmt.Errorf("镜像 %s 引用的主机不存在: %s", img.Name, host)
+			}
+		}
+		if len(c.TargetsFor(img)) == 0 {
+			return fmt.Errorf("镜像 %s 没有匹配的目标主机", img.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+
+	"dockship/internal/hostpattern"
+)
+
+// HostConfig 目标主机配置（支持纯字符串或带连接参数的结构体）
+// 结构体中未配置的字段沿用 ssh_config 或全局 ssh 配置
+type HostConfig struct {
+	Address       string            `mapstructure:"address"`         // 主机地址或 ssh_config 别名
+	Port          int               `mapstructure:"port"`            // SSH端口
+	User          string            `mapstructure:"user"`            // SSH用户名
+	KeyFile       string            `mapstructure:"key_file"`        // SSH私钥文件路径
+	CertFile      string            `mapstructure:"cert_file"`       // SSH用户证书
+	Password      string            `mapstructure:"pwd"`             // SSH密码
+	RemoteTempDir string            `mapstructure:"remote_temp_dir"` // 远程临时文件目录
+	Labels        map[string]string `mapstructure:"labels"`          // 主机标签
+	JumpHosts     []JumpHostConfig  `mapstructure:"jump_hosts"`      // 跳板链（覆盖全局）
+	Become        *BecomeConfig     `mapstructure:"become"`          // 提权配置（与全局合并）
+
+	BandwidthLimit string `mapstructure:"bandwidth_limit"` // 该主机的上传限速（覆盖全局平分的配额上限）
+
+	Name string `mapstructure:"-"` // 主机名称（address[:port]，不含用户名，用于展示和匹配）
+}
+
+// parseTargetHosts 手动解析target_hosts字段，兼容纯字符串和结构体两种YAML写法
+func parseTargetHosts(cfg *Config) error {
+	hostsRaw := viper.Get("target_hosts")
+	if hostsRaw == nil {
+		return nil
+	}
+
+	hostsSlice, ok := hostsRaw.([]interface{})
+	if !ok {
+		return fmt.Errorf("target_hosts 必须是列表")
+	}
+
+	cfg.TargetHosts = make([]HostConfig, 0, len(hostsSlice))
+	for i, item := range hostsSlice {
+		hosts, err := parseHostEntry(item)
+		if err != nil {
+			return fmt.Errorf("target_hosts[%d]: %w", i, err)
+		}
+		cfg.TargetHosts = append(cfg.TargetHosts, hosts...)
+		if pattern := entryAddress(item); hostpattern.IsPattern(pattern) {
+			cfg.Expansions = append(cfg.Expansions, HostExpansion{Pattern: pattern, Count: len(hosts)})
+		}
+	}
+	return nil
+}
+
+// entryAddress 返回主机条目中地址的写法：纯字符串本身或结构体的 address
+func entryAddress(item interface{}) string {
+	switch v := item.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		address, _ := v["address"].(string)
+		return address
+	}
+	return ""
+}
+
+// parseHostEntry 解析单个主机条目，地址中的范围和网段会展开为多台主机
+func parseHostEntry(item interface{}) ([]HostConfig, error) {
+	switch v := item.(type) {
+	case string:
+		// 纯字符串写法:
+		//   - 192.168.1.10
+		//   - deploy@192.168.1.10:2222
+		//   - [fd00::10]:2222
+		//   - 10.0.3.[10:49]
+		//   - node[01:20].dc1
+		//   - 10.0.5.0/28
+		names, err := hostpattern.Expand(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]HostConfig, 0, len(names))
+		for _, name := range names {
+			host, err := ParseHostString(name)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, host)
+		}
+		return hosts, nil
+	case map[string]interface{}:
+		// 结构体写法:
+		//   - address: 192.168.1.10
+		//     port: 2222
+		//     user: deploy
+		// address 同样支持范围和网段，展开后的每台主机使用相同的连接参数
+		var tmpl HostConfig
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			Result:           &tmpl,
+			WeaklyTypedInput: true,
+			ErrorUnused:      true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(v); err != nil {
+			return nil, err
+		}
+		if tmpl.Address == "" {
+			return nil, fmt.Errorf("主机地址 address 不能为空")
+		}
+
+		addresses, err := hostpattern.Expand(tmpl.Address)
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]HostConfig, 0, len(addresses))
+		for _, address := range addresses {
+			host, err := hostFromTemplate(tmpl, address)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, host)
+		}
+		return hosts, nil
+	default:
+		return nil, fmt.Errorf("不支持的主机配置类型: %T", item)
+	}
+}
+
+// hostFromTemplate 以结构体写法的主机条目为模板，生成指定地址的主机配置
+func hostFromTemplate(tmpl HostConfig, address string) (HostConfig, error) {
+	host := tmpl
+	if tmpl.Labels != nil {
+		host.Labels = make(map[string]string, len(tmpl.Labels))
+		for k, v := range tmpl.Labels {
+			host.Labels[k] = v
+		}
+	}
+
+	// address 中也允许带端口
+	parsed, err := ParseHostString(address)
+	if err != nil {
+		return host, err
+	}
+	host.Address = parsed.Address
+	if host.Port == 0 {
+		host.Port = parsed.Port
+	}
+	if host.User == "" {
+		host.User = parsed.User
+	}
+	host.Name = hostName(host.Address, host.Port)
+	return host, nil
+}
+
+// ParseHostString 解析 [user@]host[:port] 格式的主机，IPv6 地址带端口时需要写成 [addr]:port
+// 主机名称统一为 address[:port]，与结构体写法一致，不论配置中是否写了用户名
+func ParseHostString(s string) (HostConfig, error) {
+	s = strings.TrimSpace(s)
+	var host HostConfig
+
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		host.User = s[:i]
+		s = s[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(s, "["):
+		// [ipv6] 或 [ipv6]:port
+		if strings.HasSuffix(s, "]") {
+			host.Address = s[1 : len(s)-1]
+			break
+		}
+		addr, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return host, fmt.Errorf("主机地址无效: %s", s)
+		}
+		host.Address = addr
+		if host.Port, err = parsePort(port); err != nil {
+			return host, err
+		}
+	case strings.Count(s, ":") == 1:
+		// host:port
+		addr, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return host, fmt.Errorf("主机地址无效: %s", s)
+		}
+		host.Address = addr
+		if host.Port, err = parsePort(port); err != nil {
+			return host, err
+		}
+	default:
+		// 主机名、IPv4 或不带端口的 IPv6
+		host.Address = s
+	}
+
+	if host.Address == "" {
+		return host, fmt.Errorf("主机地址不能为空")
+	}
+	host.Name = hostName(host.Address, host.Port)
+	return host, nil
+}
+
+// hostName 返回 address[:port] 形式的主机名称，未指定端口时只有地址
+func hostName(address string, port int) string {
+	if port == 0 {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// hostRef 将引用主机的写法（镜像的 hosts、exclude_hosts 等）规范为主机名称，
+// 带用户名的写法也能匹配到同一台主机；无法解析时原样返回
+func hostRef(s string) string {
+	host, err := ParseHostString(s)
+	if err != nil {
+		return s
+	}
+	return host.Name
+}
+
+// parsePort 解析端口号
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("端口无效: %s", s)
+	}
+	return port, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseHostString(t *testing.T) {
+	tests := []struct {
+		in      string
+		name    string
+		address string
+		port    int
+		user    string
+	}{
+		{in: "192.168.1.10", name: "192.168.1.10", address: "192.168.1.10"},
+		{in: " 192.168.1.10 ", name: "192.168.1.10", address: "192.168.1.10"},
+		{in: "10.0.0.1:2222", name: "10.0.0.1:2222", address: "10.0.0.1", port: 2222},
+		{in: "deploy@10.0.0.1:2222", name: "10.0.0.1:2222", address: "10.0.0.1", port: 2222, user: "deploy"},
+		{in: "deploy@web1", name: "web1", address: "web1", user: "deploy"},
+		{in: "a@b@web1", name: "web1", address: "web1", user: "a@b"},
+		{in: "fd00::10", name: "fd00::10", address: "fd00::10"},
+		{in: "[fd00::10]", name: "fd00::10", address: "fd00::10"},
+		{in: "[fd00::10]:2222", name: "[fd00::10]:2222", address: "fd00::10", port: 2222},
+		{in: "root@[fd00::10]:22", name: "[fd00::10]:22", address: "fd00::10", port: 22, user: "root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			host, err := ParseHostString(tt.in)
+			if err != nil {
+				t.Fatalf("ParseHostString(%q): %v", tt.in, err)
+			}
+			if host.Name != tt.name || host.Address != tt.address || host.Port != tt.port || host.User != tt.user {
+				t.Errorf("ParseHostString(%q) = {Name: %q, Address: %q, Port: %d, User: %q}, want {Name: %q, Address: %q, Port: %d, User: %q}",
+					tt.in, host.Name, host.Address, host.Port, host.User, tt.name, tt.address, tt.port, tt.user)
+			}
+		})
+	}
+}
+
+func TestParseHostStringInvalid(t *testing.T) {
+	for _, in := range []string{"", "deploy@", "10.0.0.1:0", "10.0.0.1:65536", "web1:ssh", "[fd00::10]:x"} {
+		if _, err := ParseHostString(in); err == nil {
+			t.Errorf("ParseHostString(%q) 应返回错误", in)
+		}
+	}
+}
+
+// 纯字符串和结构体写法得到相同的主机名称，按名称匹配的配置不受写法影响
+func TestHostNameIndependentOfSpelling(t *testing.T) {
+	fromString, err := parseHostEntry("deploy@10.0.0.1:2222")
+	if err != nil {
+		t.Fatalf("parseHostEntry(string): %v", err)
+	}
+	fromStruct, err := parseHostEntry(map[string]interface{}{
+		"address": "10.0.0.1",
+		"port":    2222,
+		"user":    "deploy",
+	})
+	if err != nil {
+		t.Fatalf("parseHostEntry(struct): %v", err)
+	}
+	if fromString[0].Name != fromStruct[0].Name {
+		t.Errorf("主机名称不一致: 字符串写法 %q，结构体写法 %q", fromString[0].Name, fromStruct[0].Name)
+	}
+	if got := hostRef("deploy@10.0.0.1:2222"); got != fromStruct[0].Name {
+		t.Errorf("hostRef = %q, want %q", got, fromStruct[0].Name)
+	}
+}
+
+func TestParseHostEntryExpands(t *testing.T) {
+	hosts, err := parseHostEntry("deploy@10.0.3.[10:12]:2222")
+	if err != nil {
+		t.Fatalf("parseHostEntry: %v", err)
+	}
+	want := []string{"10.0.3.10:2222", "10.0.3.11:2222", "10.0.3.12:2222"}
+	if len(hosts) != len(want) {
+		t.Fatalf("展开得到 %d 台主机，want %d", len(hosts), len(want))
+	}
+	for i, host := range hosts {
+		if host.Name != want[i] || host.User != "deploy" {
+			t.Errorf("hosts[%d] = {Name: %q, User: %q}, want {Name: %q, User: %q}", i, host.Name, host.User, want[i], "deploy")
+		}
+	}
+}
+
+func TestTargetHostExpansions(t *testing.T) {
+	viper.Set("target_hosts", []interface{}{
+		"10.0.3.[10:49]",
+		"192.168.1.10",
+		map[string]interface{}{"address": "10.0.5.0/29", "user": "deploy"},
+	})
+	defer viper.Set("target_hosts", nil)
+
+	var cfg Config
+	if err := parseTargetHosts(&cfg); err != nil {
+		t.Fatalf("parseTargetHosts: %v", err)
+	}
+	if len(cfg.TargetHosts) != 47 {
+		t.Errorf("TargetHosts 有 %d 台主机, want 47", len(cfg.TargetHosts))
+	}
+	want := "10.0.3.[10:49] → 40 台，10.0.5.0/29 → 6 台"
+	if got := cfg.ExpansionSummary(); got != want {
+		t.Errorf("ExpansionSummary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"dockship/internal/inventory"
+)
+
+// loadInventory 从 Ansible 主机清单加载目标主机和主机组
+// 与 target_hosts 中同名的主机以 dockship 配置为准，同名主机组会合并
+func loadInventory(cfg *Config) error {
+	if cfg.Inventory == "" {
+		return nil
+	}
+
+	inv, err := inventory.Load(ExpandPath(cfg.Inventory))
+	if err != nil {
+		return err
+	}
+
+	for _, h := range inv.Hosts {
+		host, err := hostFromInventory(h)
+		if err != nil {
+			return err
+		}
+		if !cfg.hasTargetHost(host.Name) {
+			cfg.TargetHosts = append(cfg.TargetHosts, host)
+		}
+	}
+
+	for _, g := range inv.Groups {
+		hosts := inv.GroupHosts(g.Name)
+		if len(hosts) == 0 {
+			continue
+		}
+		group, ok := cfg.group(g.Name)
+		if !ok {
+			cfg.HostGroups = append(cfg.HostGroups, HostGroup{Name: strings.ToLower(g.Name)})
+			group = &cfg.HostGroups[len(cfg.HostGroups)-1]
+		}
+		for _, host := range hosts {
+			if !containsString(group.Hosts, host) {
+				group.Hosts = append(group.Hosts, host)
+			}
+		}
+	}
+	return nil
+}
+
+// hostFromInventory 将清单中的主机变量映射为主机配置
+//
+//	ansible_host                         -> address
+//	ansible_port / ansible_ssh_port      -> port
+//	ansible_user / ansible_ssh_user      -> user
+//	ansible_password / ansible_ssh_pass  -> pwd
+//	ansible_ssh_private_key_file         -> key_file
+//	ansible_become                       -> become（true 时默认 sudo）
+//	ansible_become_method/user/password  -> become.method/user/pwd
+//
+// 其他非 ansible_ 开头的变量作为主机标签；清单中的主机名可以带端口（web1:2222），ansible_port 优先
+func hostFromInventory(h inventory.Host) (HostConfig, error) {
+	addr, err := ParseHostString(h.Name)
+	if err != nil {
+		return HostConfig{}, fmt.Errorf("主机清单中的主机 %s 无效: %w", h.Name, err)
+	}
+	host := HostConfig{
+		Name:    h.Name,
+		Address: addr.Address,
+		Port:    addr.Port,
+		Labels:  make(map[string]string),
+	}
+
+	becomeFlag := ""
+	for k, v := range h.Vars {
+		var err error
+		switch k {
+		case "ansible_host", "ansible_ssh_host":
+			host.Address = v
+		case "ansible_port", "ansible_ssh_port":
+			host.Port, err = parsePort(v)
+		case "ansible_user", "ansible_ssh_user":
+			host.User = v
+		case "ansible_password", "ansible_ssh_pass":
+			host.Password = v
+		case "ansible_ssh_private_key_file":
+			host.KeyFile = v
+		case "ansible_become":
+			becomeFlag = strings.ToLower(v)
+		case "ansible_become_method":
+			inventoryBecome(&host).Method = v
+		case "ansible_become_user":
+			inventoryBecome(&host).User = v
+		case "ansible_become_password", "ansible_become_pass":
+			inventoryBecome(&host).Password = v
+		default:
+			if !strings.HasPrefix(k, "ansible_") {
+				host.Labels[k] = v
+			}
+		}
+		if err != nil {
+			return host, fmt.Errorf("主机清单中主机 %s 的 %s 无效: %w", h.Name, k, err)
+		}
+	}
+
+	// ansible_become 为 false 时即使配置了提权方式也不提权，为 true 时默认使用 sudo
+	switch becomeFlag {
+	case "":
+	case "true", "yes", "1":
+		if become := inventoryBecome(&host); become.Method == "" {
+			become.Method = "sudo"
+		}
+	default:
+		inventoryBecome(&host).Method = "none"
+	}
+	return host, nil
+}
+
+// inventoryBecome 返回主机的提权配置，不存在时创建
+func inventoryBecome(host *HostConfig) *BecomeConfig {
+	if host.Become == nil {
+		host.Become = &BecomeConfig{}
+	}
+	return host.Become
+}
+
+// hasTargetHost 判断目标主机列表中是否已有同名主机
+func (c *Config) hasTargetHost(name string) bool {
+	for _, host := range c.TargetHosts {
+		if host.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString 判断列表中是否包含字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
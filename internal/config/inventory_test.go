@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadInventoryHostPort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := `
+[web]
+web1:2222
+web2:2222 ansible_port=2200
+web3 ansible_host=10.0.0.3
+[fd00::4]:2222
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Inventory: path}
+	if err := loadInventory(cfg); err != nil {
+		t.Fatalf("loadInventory: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		port    int
+	}{
+		{"web1:2222", "web1", 2222},
+		// ansible_port 优先于主机名中的端口
+		{"web2:2222", "web2", 2200},
+		{"web3", "10.0.0.3", 0},
+		{"[fd00::4]:2222", "fd00::4", 2222},
+	}
+	if len(cfg.TargetHosts) != len(tests) {
+		t.Fatalf("TargetHosts 有 %d 台主机, want %d", len(cfg.TargetHosts), len(tests))
+	}
+	for i, tt := range tests {
+		host := cfg.TargetHosts[i]
+		if host.Name != tt.name || host.Address != tt.address || host.Port != tt.port {
+			t.Errorf("TargetHosts[%d] = {Name: %q, Address: %q, Port: %d}, want {Name: %q, Address: %q, Port: %d}",
+				i, host.Name, host.Address, host.Port, tt.name, tt.address, tt.port)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"dockship/internal/sshconfig"
+)
+
+// Target 解析后的目标主机连接参数
+type Target struct {
+	Name      string           // 配置中填写的主机名（地址或 ssh_config 别名）
+	Address   string           // 实际连接的地址
+	Port      int              // SSH端口
+	User      string           // SSH用户名
+	KeyFiles  []string         // SSH私钥文件，按顺序尝试
+	CertFiles []string         // SSH用户证书
+	Password  string           // SSH密码
+	JumpHosts []JumpHostConfig // 跳板链
+
+	RemoteTempDir string            // 远程临时文件目录
+	Labels        map[string]string // 主机标签
+	Become        BecomeConfig      // 提权配置
+
+	BandwidthLimit int64 // 该主机的上传限速（字节/秒），0 表示不单独限速
+
+	SSHConfigKeys []string // 取自 ssh_config 的配置项（用于展示）
+}
+
+// resolveTargets 解析目标主机的连接参数
+// 优先级：target_hosts 条目中的配置 > ssh_config 中匹配的配置 > 全局 ssh 配置
+func (c *Config) resolveTargets() error {
+	var sshCfg *sshconfig.Config
+	if c.SSH.SSHConfigFile != "" {
+		parsed, err := sshconfig.Load(c.SSH.SSHConfigFile)
+		if err != nil {
+			return err
+		}
+		sshCfg = parsed
+	}
+
+	c.Targets = make([]Target, 0, len(c.TargetHosts))
+	for _, host := range c.TargetHosts {
+		target, err := c.resolveTarget(host, sshCfg)
+		if err != nil {
+			return err
+		}
+		c.Targets = append(c.Targets, target)
+	}
+	return c.resolveRelaySeed(sshCfg)
+}
+
+// resolveTarget 解析单台主机的连接参数
+func (c *Config) resolveTarget(host HostConfig, sshCfg *sshconfig.Config) (Target, error) {
+	target := Target{
+		Name:          host.Name,
+		Address:       host.Address,
+		Port:          c.SSH.Port,
+		User:          c.SSH.User,
+		KeyFiles:      c.SSH.AllKeyFiles(),
+		CertFiles:     c.SSH.CertFiles(),
+		Password:      c.SSH.Password,
+		JumpHosts:     c.SSH.JumpHosts,
+		RemoteTempDir: c.RemoteStorage.TempDir,
+	}
+
+	// 主机地址可以是 ssh_config 中的别名
+	if sshCfg != nil {
+		resolved, err := sshCfg.Resolve(host.Address)
+		if err != nil {
+			return target, err
+		}
+		target.applySSHConfig(resolved)
+	}
+
+	if hops, ok := c.SSH.hostJumpHosts(host.Name); ok {
+		target.JumpHosts = hops
+	}
+	target.applyHostConfig(host)
+	target.Become = c.Become.merge(host.Become)
+	target.Become.resolvePassword()
+
+	rate, err := ParseBandwidth(host.BandwidthLimit)
+	if err != nil {
+		return target, fmt.Errorf("主机 %s 的 bandwidth_limit 无效: %w", host.Name, err)
+	}
+	target.BandwidthLimit = rate
+	return target, nil
+}
+
+// resolveRelaySeed 解析中继分发的种子主机：目标主机名称或地址对应该目标主机，
+// 否则作为只负责转发的中转主机（[user@]host[:port]），连接参数沿用全局 ssh 配置
+func (c *Config) resolveRelaySeed(sshCfg *sshconfig.Config) error {
+	relay := c.Transfer.Relay
+	if !relay.Enabled || relay.Seed == "" {
+		return nil
+	}
+	seed := map[string]bool{hostRef(relay.Seed): true}
+	for _, target := range c.Targets {
+		if target.in(seed) {
+			c.RelaySeed = &target
+			return nil
+		}
+	}
+
+	host, err := ParseHostString(relay.Seed)
+	if err != nil {
+		return fmt.Errorf("transfer.relay.seed 无效: %w", err)
+	}
+	target, err := c.resolveTarget(host, sshCfg)
+	if err != nil {
+		return fmt.Errorf("transfer.relay.seed: %w", err)
+	}
+	c.RelaySeed = &target
+	return nil
+}
+
+// applyHostConfig 应用 target_hosts 条目中按主机配置的连接参数
+func (t *Target) applyHostConfig(host HostConfig) {
+	if host.Port != 0 {
+		t.Port = host.Port
+	}
+	if host.User != "" {
+		t.User = host.User
+	}
+	if host.KeyFile != "" {
+		t.KeyFiles = []string{host.KeyFile}
+	}
+	if host.CertFile != "" {
+		t.CertFiles = []string{host.CertFile}
+	}
+	if host.Password != "" {
+		t.Password = host.Password
+	}
+	if host.RemoteTempDir != "" {
+		t.RemoteTempDir = host.RemoteTempDir
+	}
+	if len(host.JumpHosts) > 0 {
+		t.JumpHosts = host.JumpHosts
+	}
+	t.Labels = host.Labels
+}
+
+// applySSHConfig 应用 ssh_config 中解析出的配置
+func (t *Target) applySSHConfig(host *sshconfig.Host) {
+	if host.HostName != "" {
+		t.Address = host.HostName
+	}
+	if host.User != "" {
+		t.User = host.User
+	}
+	if host.Port != 0 {
+		t.Port = host.Port
+	}
+	// ssh_config 中的私钥优先尝试，不存在的私钥文件与 OpenSSH 一样直接忽略
+	if keyFiles := existingFiles(host.IdentityFiles); len(keyFiles) > 0 {
+		t.KeyFiles = append(keyFiles, t.KeyFiles...)
+	}
+	if certFiles := existingFiles(host.CertificateFiles); len(certFiles) > 0 {
+		t.CertFiles = append(certFiles, t.CertFiles...)
+	}
+	if len(host.ProxyJump) > 0 {
+		t.JumpHosts = make([]JumpHostConfig, 0, len(host.ProxyJump))
+		for _, hop := range host.ProxyJump {
+			jump := JumpHostConfig{
+				Address: hop.Host,
+				Port:    hop.Port,
+				User:    hop.User,
+			}
+			if keyFiles := existingFiles(hop.IdentityFiles); len(keyFiles) > 0 {
+				jump.KeyFile = keyFiles[0]
+			}
+			if certFiles := existingFiles(hop.CertificateFiles); len(certFiles) > 0 {
+				jump.CertFile = certFiles[0]
+			}
+			t.JumpHosts = append(t.JumpHosts, jump)
+		}
+	}
+	t.SSHConfigKeys = host.Keys
+}
+
+// existingFiles 过滤出存在的文件
+func existingFiles(files []string) []string {
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing
+}
+
+// String 返回 user@address:port 形式的描述
+func (t *Target) String() string {
+	return t.User + "@" + net.JoinHostPort(t.Address, strconv.Itoa(t.Port))
+}
+
+// in 判断主机名称或地址是否在集合中
+func (t *Target) in(set map[string]bool) bool {
+	return set[t.Name] || set[t.Address]
+}
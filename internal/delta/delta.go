@@ -0,0 +1,387 @@
+// Package delta 实现 rsync 风格的二进制增量：根据远程基准文件的块签名，
+// 用滚动校验和在新文件中查找相同的块，只发送基准文件中没有的数据
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// deltaMagic 增量文件头
+const deltaMagic = "DSDELTA1"
+
+// maxLiteral 单条字面数据指令的最大长度，限制计算增量时的内存占用
+const maxLiteral = 1 << 20
+
+// 增量指令
+const (
+	opCopy    = 'C' // 复制基准文件中连续的若干块：起始块号 uint32 + 块数 uint32
+	opLiteral = 'D' // 字面数据：长度 uint32 + 数据
+	opEnd     = 'E' // 结束：新文件大小 uint64 + SHA-256
+)
+
+// ErrCorrupt 增量文件损坏，或基准文件与计算增量时使用的签名不一致
+var ErrCorrupt = errors.New("增量数据无效")
+
+// Result 增量的统计信息
+type Result struct {
+	Size      int64  // 新文件大小
+	Sum       string // 新文件的 SHA-256（十六进制）
+	Copied    int64  // 从基准文件复制的字节数
+	Literal   int64  // 随增量发送的字节数
+	DeltaSize int64  // 增量文件大小
+}
+
+// Diff 根据基准文件的签名计算 r 的增量，写入 w
+func Diff(sig *Signature, r io.Reader, w io.Writer) (*Result, error) {
+	bs := sig.BlockSize
+	index := make(map[uint32][]int, len(sig.Weak))
+	filter := make([]uint64, filterSize/64) // 弱校验和的位图，绝大多数位置不需要查 map
+	for i, weak := range sig.Weak {
+		if sig.blockLen(i) == bs {
+			index[weak] = append(index[weak], i)
+			slot := filterSlot(weak)
+			filter[slot/64] |= 1 << (slot % 64)
+		}
+	}
+
+	h := sha256.New()
+	src := io.TeeReader(r, h)
+	enc := newEncoder(w, bs)
+	res := &Result{}
+
+	// buf 中 lit 之前的数据已经写出，[lit, p) 为待写出的字面数据，p 为当前窗口的起点
+	buf := make([]byte, 0, maxLiteral+2*bs+256*1024)
+	p, lit := 0, 0
+	eof := false
+	fill := func(need int) error {
+		for !eof && len(buf)-p < need {
+			if lit > 0 {
+				n := copy(buf, buf[lit:])
+				buf = buf[:n]
+				p -= lit
+				lit = 0
+			}
+			if len(buf) == cap(buf) {
+				buf = slices.Grow(buf, bs)
+			}
+			n, err := src.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return fmt.Errorf("读取文件失败: %w", err)
+			}
+		}
+		return nil
+	}
+	literal := func(data []byte) error {
+		res.Literal += int64(len(data))
+		return enc.literal(data)
+	}
+
+	var a, b uint32
+	rolling := false
+	for {
+		// 多读一个字节用于滚动
+		if err := fill(bs + 1); err != nil {
+			return nil, err
+		}
+		if len(buf)-p < bs {
+			break
+		}
+		if !rolling {
+			weak := weakSum(buf[p : p+bs])
+			a, b = weak&0xffff, weak>>16
+			rolling = true
+		}
+		weak := a | b<<16
+		if slot := filterSlot(weak); filter[slot/64]&(1<<(slot%64)) != 0 {
+			if i := sig.match(index[weak], buf[p:p+bs]); i >= 0 {
+				if p > lit {
+					if err := literal(buf[lit:p]); err != nil {
+						return nil, err
+					}
+				}
+				if err := enc.copy(i); err != nil {
+					return nil, err
+				}
+				res.Copied += int64(bs)
+				p += bs
+				lit = p
+				rolling = false
+				continue
+			}
+		}
+		if len(buf)-p == bs {
+			break // 已到文件末尾
+		}
+
+		// 窗口后移一个字节
+		out, in := uint32(buf[p]), uint32(buf[p+bs])
+		a = (a - out + in) & 0xffff
+		b = (b - uint32(bs)*out + a) & 0xffff
+		p++
+		if p-lit >= maxLiteral {
+			if err := literal(buf[lit:p]); err != nil {
+				return nil, err
+			}
+			lit = p
+		}
+	}
+
+	// 文件末尾不足一块的部分与基准文件同样不足一块的最后一块比较
+	tail, last := len(buf), len(sig.Weak)-1
+	tailCopy := false
+	if last >= 0 {
+		if n := sig.blockLen(last); n < bs && tail-n >= lit && weakSum(buf[tail-n:]) == sig.Weak[last] && sig.match([]int{last}, buf[tail-n:]) == last {
+			tail -= n
+			tailCopy = true
+		}
+	}
+	if tail > lit {
+		if err := literal(buf[lit:tail]); err != nil {
+			return nil, err
+		}
+	}
+	if tailCopy {
+		if err := enc.copy(last); err != nil {
+			return nil, err
+		}
+		res.Copied += int64(len(buf) - tail)
+	}
+
+	res.Sum = hex.EncodeToString(h.Sum(nil))
+	res.Size = res.Copied + res.Literal
+	if err := enc.end(res.Size, h.Sum(nil)); err != nil {
+		return nil, err
+	}
+	res.DeltaSize = enc.written
+	return res, nil
+}
+
+// filterSize 弱校验和位图的位数
+const filterSize = 1 << 20
+
+// filterSlot 弱校验和在位图中的位置
+func filterSlot(weak uint32) uint32 {
+	return (weak * 0x9e3779b1) >> 12
+}
+
+// blockLen 第 i 块的长度
+func (s *Signature) blockLen(i int) int {
+	if i == len(s.Weak)-1 {
+		if rem := int(s.Size % int64(s.BlockSize)); rem != 0 {
+			return rem
+		}
+	}
+	return s.BlockSize
+}
+
+// match 在弱校验和相同的候选块中查找强校验和也相同的块，没有时返回 -1
+func (s *Signature) match(candidates []int, block []byte) int {
+	if len(candidates) == 0 {
+		return -1
+	}
+	strong := strongSum(block)
+	for _, i := range candidates {
+		if s.Strong[i] == strong {
+			return i
+		}
+	}
+	return -1
+}
+
+// encoder 写出增量指令，连续块的复制合并为一条指令
+type encoder struct {
+	w       *bufio.Writer
+	written int64
+
+	runStart, runLen int
+}
+
+func newEncoder(w io.Writer, blockSize int) *encoder {
+	e := &encoder{w: bufio.NewWriterSize(w, 256*1024)}
+	header := binary.BigEndian.AppendUint32([]byte(deltaMagic), uint32(blockSize))
+	e.write(header)
+	return e
+}
+
+func (e *encoder) write(p []byte) error {
+	n, err := e.w.Write(p)
+	e.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("写入增量失败: %w", err)
+	}
+	return nil
+}
+
+func (e *encoder) copy(block int) error {
+	if e.runLen > 0 && block == e.runStart+e.runLen {
+		e.runLen++
+		return nil
+	}
+	if err := e.flushRun(); err != nil {
+		return err
+	}
+	e.runStart, e.runLen = block, 1
+	return nil
+}
+
+func (e *encoder) flushRun() error {
+	if e.runLen == 0 {
+		return nil
+	}
+	op := []byte{opCopy}
+	op = binary.BigEndian.AppendUint32(op, uint32(e.runStart))
+	op = binary.BigEndian.AppendUint32(op, uint32(e.runLen))
+	e.runLen = 0
+	return e.write(op)
+}
+
+func (e *encoder) literal(data []byte) error {
+	if err := e.flushRun(); err != nil {
+		return err
+	}
+	op := binary.BigEndian.AppendUint32([]byte{opLiteral}, uint32(len(data)))
+	if err := e.write(op); err != nil {
+		return err
+	}
+	return e.write(data)
+}
+
+func (e *encoder) end(size int64, sum []byte) error {
+	if err := e.flushRun(); err != nil {
+		return err
+	}
+	op := binary.BigEndian.AppendUint64([]byte{opEnd}, uint64(size))
+	if err := e.write(append(op, sum...)); err != nil {
+		return err
+	}
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("写入增量失败: %w", err)
+	}
+	return nil
+}
+
+// instruction 解析出的一条增量指令
+type instruction struct {
+	op     byte
+	start  int64  // opCopy: 起始块号；opLiteral: 数据在增量文件中的偏移
+	length int64  // opCopy: 块数；opLiteral: 数据长度；opEnd: 新文件大小
+	sum    []byte // opEnd: 新文件的 SHA-256
+}
+
+// decoder 依次解析增量文件中的指令
+type decoder struct {
+	br        *bufio.Reader
+	blockSize int
+	offset    int64             // 已解析的字节数
+	pending   *io.LimitedReader // 上一条字面数据指令未读完的数据
+}
+
+// newDecoder 读取增量文件头
+func newDecoder(delta io.Reader) (*decoder, error) {
+	d := &decoder{br: bufio.NewReaderSize(delta, 256*1024)}
+	header := make([]byte, len(deltaMagic)+4)
+	if _, err := io.ReadFull(d.br, header); err != nil || string(header[:len(deltaMagic)]) != deltaMagic {
+		return nil, fmt.Errorf("增量文件头无效: %w", ErrCorrupt)
+	}
+	d.blockSize = int(binary.BigEndian.Uint32(header[len(deltaMagic):]))
+	if d.blockSize <= 0 {
+		return nil, fmt.Errorf("增量文件头无效: %w", ErrCorrupt)
+	}
+	d.offset = int64(len(header))
+	return d, nil
+}
+
+// next 解析下一条指令；字面数据指令同时返回数据，调用方可以不读取
+func (d *decoder) next() (instruction, io.Reader, error) {
+	if d.pending != nil {
+		if _, err := io.Copy(io.Discard, d.pending); err != nil || d.pending.N > 0 {
+			return instruction{}, nil, fmt.Errorf("增量文件不完整: %w", ErrCorrupt)
+		}
+		d.pending = nil
+	}
+
+	op, err := d.br.ReadByte()
+	if err != nil {
+		return instruction{}, nil, fmt.Errorf("增量文件不完整: %w", ErrCorrupt)
+	}
+	d.offset++
+	var argSize int
+	switch op {
+	case opCopy:
+		argSize = 8
+	case opLiteral:
+		argSize = 4
+	case opEnd:
+		argSize = 8 + sha256.Size
+	default:
+		return instruction{}, nil, fmt.Errorf("未知的增量指令 %q: %w", op, ErrCorrupt)
+	}
+	args := make([]byte, argSize)
+	if _, err := io.ReadFull(d.br, args); err != nil {
+		return instruction{}, nil, fmt.Errorf("增量文件不完整: %w", ErrCorrupt)
+	}
+	d.offset += int64(argSize)
+
+	switch op {
+	case opCopy:
+		return instruction{op: op, start: int64(binary.BigEndian.Uint32(args)), length: int64(binary.BigEndian.Uint32(args[4:]))}, nil, nil
+	case opLiteral:
+		ins := instruction{op: op, start: d.offset, length: int64(binary.BigEndian.Uint32(args))}
+		d.offset += ins.length
+		d.pending = &io.LimitedReader{R: d.br, N: ins.length}
+		return ins, d.pending, nil
+	default:
+		return instruction{op: op, length: int64(binary.BigEndian.Uint64(args)), sum: args[8:]}, nil, nil
+	}
+}
+
+// Patch 将增量应用到基准文件，把重建的新文件写入 out，并校验新文件的大小和 SHA-256
+func Patch(basis io.ReaderAt, delta io.Reader, out io.Writer) error {
+	d, err := newDecoder(delta)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	w := bufio.NewWriterSize(io.MultiWriter(out, h), 256*1024)
+	var written int64
+
+	for {
+		ins, data, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch ins.op {
+		case opCopy:
+			section := io.NewSectionReader(basis, ins.start*int64(d.blockSize), ins.length*int64(d.blockSize))
+			n, err := io.Copy(w, section)
+			written += n
+			if err != nil {
+				return fmt.Errorf("读取基准文件失败: %w", err)
+			}
+		case opLiteral:
+			n, err := io.Copy(w, data)
+			written += n
+			if err != nil {
+				return fmt.Errorf("写入文件失败: %w", err)
+			}
+		case opEnd:
+			if err := w.Flush(); err != nil {
+				return fmt.Errorf("写入文件失败: %w", err)
+			}
+			if written != ins.length || !slices.Equal(h.Sum(nil), ins.sum) {
+				return fmt.Errorf("重建的文件与原文件不一致（基准文件已变化？）: %w", ErrCorrupt)
+			}
+			return nil
+		}
+	}
+}
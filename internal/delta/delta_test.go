@@ -0,0 +1,165 @@
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const testBlockSize = 64
+
+// randomBytes 生成固定种子的伪随机数据
+func randomBytes(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// concat 拼接多段数据
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// roundTrip 计算 target 相对 basis 的增量并重建，返回增量的统计信息和增量数据
+func roundTrip(t *testing.T, basis, target []byte) (*Result, []byte) {
+	t.Helper()
+
+	sig, err := ComputeSignature(bytes.NewReader(basis), testBlockSize)
+	if err != nil {
+		t.Fatalf("ComputeSignature: %v", err)
+	}
+
+	// 签名经过序列化，与实际使用时（远程计算或本地缓存）一致
+	var sigBuf bytes.Buffer
+	if _, err := sig.WriteTo(&sigBuf); err != nil {
+		t.Fatalf("Signature.WriteTo: %v", err)
+	}
+	sig, err = ReadSignature(&sigBuf)
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+
+	var delta bytes.Buffer
+	res, err := Diff(sig, bytes.NewReader(target), &delta)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if res.Size != int64(len(target)) {
+		t.Errorf("Result.Size = %d, want %d", res.Size, len(target))
+	}
+	if res.DeltaSize != int64(delta.Len()) {
+		t.Errorf("Result.DeltaSize = %d, want %d", res.DeltaSize, delta.Len())
+	}
+	if res.Copied+res.Literal != res.Size {
+		t.Errorf("Copied + Literal = %d, want %d", res.Copied+res.Literal, res.Size)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), bytes.NewReader(delta.Bytes()), &out); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), target) {
+		t.Fatalf("Patch 重建的数据与原数据不一致: got %d bytes, want %d bytes", out.Len(), len(target))
+	}
+	return res, delta.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	basis := randomBytes(1, 100*testBlockSize+17)
+	insert := randomBytes(2, 300)
+
+	tests := []struct {
+		name      string
+		basis     []byte
+		target    []byte
+		minCopied int64 // 至少应从基准文件复制的字节数
+	}{
+		{"identical", basis, basis, 100 * testBlockSize},
+		{"inserted", basis, concat(basis[:40*testBlockSize+5], insert, basis[40*testBlockSize+5:]), 98 * testBlockSize},
+		{"deleted", basis, concat(basis[:30*testBlockSize+3], basis[35*testBlockSize+9:]), 93 * testBlockSize},
+		{"shifted", basis, concat([]byte("x"), basis), 100 * testBlockSize},
+		{"replaced", basis, concat(basis[:50*testBlockSize], randomBytes(3, 2*testBlockSize), basis[52*testBlockSize:]), 97 * testBlockSize},
+		{"appended", basis, concat(basis, insert), 100 * testBlockSize},
+		{"truncated", basis, basis[:20*testBlockSize+1], 20 * testBlockSize},
+		{"target shorter than one block", basis, basis[:testBlockSize/2], 0},
+		{"basis shorter than one block", basis[:testBlockSize/2], basis[:testBlockSize], 0},
+		{"literal longer than maxLiteral", basis, concat(basis[:10*testBlockSize], randomBytes(4, 2*maxLiteral+5), basis[10*testBlockSize:]), 100 * testBlockSize},
+		{"empty basis", nil, basis, 0},
+		{"empty target", basis, nil, 0},
+		{"both empty", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, _ := roundTrip(t, tt.basis, tt.target)
+			if res.Copied < tt.minCopied {
+				t.Errorf("Copied = %d, want at least %d", res.Copied, tt.minCopied)
+			}
+		})
+	}
+}
+
+func TestPatchChangedBasis(t *testing.T) {
+	basis := randomBytes(1, 20*testBlockSize)
+	target := concat(basis[:10*testBlockSize], randomBytes(2, 100), basis[10*testBlockSize:])
+	_, delta := roundTrip(t, basis, target)
+
+	// 计算增量后基准文件发生变化，重建结果的校验和不一致
+	changed := bytes.Clone(basis)
+	changed[3] ^= 0xff
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(changed), bytes.NewReader(delta), &out); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Patch with changed basis: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	for _, tool := range []string{"sh", "dd", "tail", "head"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("没有 %s: %v", tool, err)
+		}
+	}
+
+	basis := randomBytes(1, 50*testBlockSize+9)
+	tests := []struct {
+		name   string
+		target []byte
+	}{
+		{"inserted", concat(basis[:20*testBlockSize+1], randomBytes(2, 500), basis[20*testBlockSize+1:])},
+		{"empty target", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, delta := roundTrip(t, basis, tt.target)
+
+			dir := t.TempDir()
+			basisPath := filepath.Join(dir, "basis")
+			deltaPath := filepath.Join(dir, "delta")
+			outPath := filepath.Join(dir, "out")
+			if err := os.WriteFile(basisPath, basis, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(deltaPath, delta, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			script, err := Script(bytes.NewReader(delta), basisPath, deltaPath, outPath)
+			if err != nil {
+				t.Fatalf("Script: %v", err)
+			}
+			if output, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+				t.Fatalf("执行重建脚本失败: %v: %s", err, output)
+			}
+			got, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Fatalf("脚本重建的数据与原数据不一致: got %d bytes, want %d bytes", len(got), len(tt.target))
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package delta
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Script 将增量转换为 POSIX shell 脚本，远程没有辅助程序时用 dd/tail/head 重建新文件
+// 复制指令从基准文件按块读取，字面数据直接从上传到远程的增量文件中截取；
+// 脚本不校验结果，执行后需要另行校验新文件的 SHA-256
+func Script(delta io.Reader, basisPath, deltaPath, outPath string) (string, error) {
+	d, err := newDecoder(delta)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("set -e\n")
+	fmt.Fprintf(&sb, "B=%s\nD=%s\n{\n", shellQuote(basisPath), shellQuote(deltaPath))
+	empty := true
+	for {
+		ins, _, err := d.next()
+		if err != nil {
+			return "", err
+		}
+		switch ins.op {
+		case opCopy:
+			empty = false
+			fmt.Fprintf(&sb, "dd if=\"$B\" bs=%d skip=%d count=%d 2>/dev/null\n", d.blockSize, ins.start, ins.length)
+		case opLiteral:
+			empty = false
+			// tail -c +N 从第 N 个字节（从 1 开始）输出
+			fmt.Fprintf(&sb, "tail -c +%d \"$D\" | head -c %d\n", ins.start+1, ins.length)
+		case opEnd:
+			if empty {
+				sb.WriteString(":\n") // 新文件为空
+			}
+			fmt.Fprintf(&sb, "} > %s\n", shellQuote(outPath))
+			return sb.String(), nil
+		}
+	}
+}
+
+// shellQuote 用单引号包裹字符串，供远程 shell 使用
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
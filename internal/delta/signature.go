@@ -0,0 +1,122 @@
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultBlockSize 默认块大小：4GB 的镜像约 65536 个块，签名约 1.3MB
+const DefaultBlockSize = 64 * 1024
+
+// strongSize 强校验和长度（SHA-256 的前 16 字节）
+const strongSize = 16
+
+// signatureMagic 签名文件头
+const signatureMagic = "DSSIG001"
+
+// Signature 基准文件的块签名：每个块的弱校验和（可滚动计算）与强校验和
+type Signature struct {
+	BlockSize int
+	Size      int64 // 基准文件大小
+	Weak      []uint32
+	Strong    [][strongSize]byte
+}
+
+// ComputeSignature 按 blockSize 分块计算 r 的签名，最后一个块可能不足 blockSize
+func ComputeSignature(r io.Reader, blockSize int) (*Signature, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("块大小无效: %d", blockSize)
+	}
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sig.Size += int64(n)
+			sig.Weak = append(sig.Weak, weakSum(buf[:n]))
+			sig.Strong = append(sig.Strong, strongSum(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取基准文件失败: %w", err)
+		}
+	}
+}
+
+// WriteTo 以二进制格式写出签名
+func (s *Signature) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, len(signatureMagic)+16)
+	header = append(header, signatureMagic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(s.BlockSize))
+	header = binary.BigEndian.AppendUint64(header, uint64(s.Size))
+	header = binary.BigEndian.AppendUint32(header, uint32(len(s.Weak)))
+	bw.Write(header)
+
+	entry := make([]byte, 4+strongSize)
+	for i, weak := range s.Weak {
+		binary.BigEndian.PutUint32(entry, weak)
+		copy(entry[4:], s.Strong[i][:])
+		bw.Write(entry)
+	}
+	n := int64(len(header) + len(s.Weak)*len(entry))
+	return n, bw.Flush()
+}
+
+// ReadSignature 读取 WriteTo 写出的签名
+func ReadSignature(r io.Reader) (*Signature, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(signatureMagic)+16)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("读取签名失败: %w", err)
+	}
+	if string(header[:len(signatureMagic)]) != signatureMagic {
+		return nil, errors.New("签名格式无效")
+	}
+	header = header[len(signatureMagic):]
+	sig := &Signature{
+		BlockSize: int(binary.BigEndian.Uint32(header)),
+		Size:      int64(binary.BigEndian.Uint64(header[4:])),
+	}
+	count := int64(binary.BigEndian.Uint32(header[12:]))
+	if sig.BlockSize <= 0 || count != (sig.Size+int64(sig.BlockSize)-1)/int64(sig.BlockSize) {
+		return nil, errors.New("签名格式无效")
+	}
+
+	sig.Weak = make([]uint32, count)
+	sig.Strong = make([][strongSize]byte, count)
+	entry := make([]byte, 4+strongSize)
+	for i := range sig.Weak {
+		if _, err := io.ReadFull(br, entry); err != nil {
+			return nil, fmt.Errorf("读取签名失败: %w", err)
+		}
+		sig.Weak[i] = binary.BigEndian.Uint32(entry)
+		copy(sig.Strong[i][:], entry[4:])
+	}
+	return sig, nil
+}
+
+// weakSum rsync 的弱校验和：a 为字节之和，b 为按位置加权的和，各取低 16 位
+func weakSum(block []byte) uint32 {
+	var a, b uint32
+	n := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a&0xffff | b<<16
+}
+
+// strongSum 块的强校验和
+func strongSum(block []byte) [strongSize]byte {
+	full := sha256.Sum256(block)
+	var sum [strongSize]byte
+	copy(sum[:], full[:strongSize])
+	return sum
+}
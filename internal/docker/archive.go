@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Archive docker save 生成的镜像归档（docker-archive 格式）
+type Archive struct {
+	Path   string
+	Layers []ArchiveLayer // 归档中各镜像的层，按 manifest.json 中的顺序
+
+	files map[string]int64  // 归档内的普通文件 -> 大小
+	links map[string]string // 归档内的符号链接 -> 链接目标（归档内路径）
+}
+
+// ArchiveLayer 归档中的一个镜像层
+type ArchiveLayer struct {
+	DiffID  string // 层内容的摘要（镜像配置中的 rootfs.diff_ids）
+	ChainID string // 从第一层到该层的链式ID，远程存在相同的链式ID说明该层及之前的所有层都已存在
+	File    string // 层文件在归档中的路径
+}
+
+// manifestEntry manifest.json 中的一个镜像
+type manifestEntry struct {
+	Config string
+	Layers []string
+}
+
+// imageConfig 镜像配置中需要的部分
+type imageConfig struct {
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// OpenArchive 解析镜像归档：读取 manifest.json 和镜像配置，计算每一层的链式ID
+func OpenArchive(archivePath string) (*Archive, error) {
+	a := &Archive{Path: archivePath, files: make(map[string]int64), links: make(map[string]string)}
+
+	// manifest.json 在归档中的位置不固定（旧格式位于末尾），先读取它，再读取其中引用的镜像配置
+	var manifest []manifestEntry
+	err := a.walk(func(hdr *tar.Header, r io.Reader) error {
+		name := path.Clean(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			a.files[name] = hdr.Size
+			if name == "manifest.json" {
+				if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+					return fmt.Errorf("解析 manifest.json 失败: %w", err)
+				}
+			}
+		case tar.TypeSymlink:
+			a.links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest) == 0 {
+		return nil, fmt.Errorf("镜像归档中没有 manifest.json: %s", archivePath)
+	}
+
+	configs := make(map[string]*imageConfig)
+	for _, entry := range manifest {
+		configs[path.Clean(entry.Config)] = nil
+	}
+	err = a.walk(func(hdr *tar.Header, r io.Reader) error {
+		name := path.Clean(hdr.Name)
+		if _, ok := configs[name]; !ok || hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		var cfg imageConfig
+		if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+			return fmt.Errorf("解析镜像配置 %s 失败: %w", name, err)
+		}
+		configs[name] = &cfg
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range manifest {
+		cfg := configs[path.Clean(entry.Config)]
+		if cfg == nil {
+			return nil, fmt.Errorf("镜像归档中缺少镜像配置: %s", entry.Config)
+		}
+		if len(cfg.RootFS.DiffIDs) != len(entry.Layers) {
+			return nil, fmt.Errorf("镜像配置的层数（%d）与 manifest.json（%d）不一致", len(cfg.RootFS.DiffIDs), len(entry.Layers))
+		}
+		for i, chainID := range ChainIDs(cfg.RootFS.DiffIDs) {
+			a.Layers = append(a.Layers, ArchiveLayer{
+				DiffID:  cfg.RootFS.DiffIDs[i],
+				ChainID: chainID,
+				File:    path.Clean(entry.Layers[i]),
+			})
+		}
+	}
+	return a, nil
+}
+
+// ChainIDs 计算各层的链式ID：第一层为其 diffID，之后每层为 sha256(上一层的链式ID + " " + diffID)
+func ChainIDs(diffIDs []string) []string {
+	chainIDs := make([]string, len(diffIDs))
+	for i, diffID := range diffIDs {
+		if i == 0 {
+			chainIDs[i] = diffID
+			continue
+		}
+		sum := sha256.Sum256([]byte(chainIDs[i-1] + " " + diffID))
+		chainIDs[i] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return chainIDs
+}
+
+// Reduce 生成省略部分层文件的精简归档，写入 dst；manifest.json、镜像配置等其他文件原样保留
+// docker load 按链式ID查找已有的层，远程已存在的层不会读取层文件，因此精简归档加载后是完整的镜像
+// 被其他保留的层引用（包括经符号链接引用）的文件不会省略；返回实际省略的层文件数和字节数
+func (a *Archive) Reduce(dst string, omit func(ArchiveLayer) bool) (int, int64, error) {
+	needed := make(map[string]bool)
+	for _, layer := range a.Layers {
+		if !omit(layer) {
+			for _, name := range a.resolve(layer.File) {
+				needed[name] = true
+			}
+		}
+	}
+	skip := make(map[string]bool)
+	var saved int64
+	for _, layer := range a.Layers {
+		if omit(layer) && !needed[layer.File] && !skip[layer.File] {
+			skip[layer.File] = true
+			saved += a.files[layer.File]
+		}
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, 0, fmt.Errorf("创建精简归档失败: %w", err)
+	}
+	tw := tar.NewWriter(out)
+
+	err = a.walk(func(hdr *tar.Header, r io.Reader) error {
+		if skip[path.Clean(hdr.Name)] {
+			return nil
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return 0, 0, fmt.Errorf("写入精简归档失败: %w", err)
+	}
+	return len(skip), saved, nil
+}
+
+// resolve 返回层文件路径及其符号链接指向的路径
+func (a *Archive) resolve(name string) []string {
+	names := []string{name}
+	for i := 0; i < 8; i++ { // 防止符号链接循环
+		target, ok := a.links[name]
+		if !ok {
+			break
+		}
+		name = target
+		names = append(names, name)
+	}
+	return names
+}
+
+// walk 依次读取归档中的每个条目
+func (a *Archive) walk(fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return fmt.Errorf("打开镜像归档失败: %w", err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取镜像归档失败: %w", err)
+		}
+		if strings.HasPrefix(path.Clean(hdr.Name), "..") {
+			return fmt.Errorf("镜像归档中的路径无效: %s", hdr.Name)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
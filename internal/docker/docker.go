@@ -32,6 +32,15 @@ func (c *Client) CheckImageExists(image string) (bool, error) {
 	return len(strings.TrimSpace(string(output))) > 0, nil
 }
 
+// ImageID 返回本地镜像的 ID（sha256:...），docker save/load 前后保持不变
+func (c *Client) ImageID(image string) (string, error) {
+	output, err := exec.Command("docker", "image", "inspect", "-f", "{{.Id}}", image).Output()
+	if err != nil {
+		return "", fmt.Errorf("获取镜像ID失败: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // PullImage 从远程仓库拉取镜像
 func (c *Client) PullImage(image string) error {
 	fmt.Printf("📥 正在拉取镜像: %s\n", image)
@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImageStream docker save 的标准输出流，用于不落盘的流式传输
+// 读到结尾时会等待 docker save 退出，退出失败时返回错误而不是 io.EOF，
+// 避免把不完整的镜像当作完整数据发送出去
+type ImageStream struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	cancel context.CancelFunc
+
+	mu     sync.Mutex // Close 可能与 Read 并发调用（批量分发时），保护进程的等待状态
+	waited bool
+	err    error
+}
+
+// SaveImageStream 启动 docker save 并返回其标准输出流
+func (c *Client) SaveImageStream(image string) (*ImageStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ImageStream{cancel: cancel}
+	s.cmd = exec.CommandContext(ctx, "docker", "save", image)
+	s.cmd.Stderr = &s.stderr
+	// 进程被终止后，最多再等待该时间让输出管道关闭，避免遗留的子进程让 Wait 一直阻塞
+	s.cmd.WaitDelay = 5 * time.Second
+
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("创建 docker save 输出管道失败: %w", err)
+	}
+	s.stdout = stdout
+
+	if err := s.cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("启动 docker save 失败: %w", err)
+	}
+	return s, nil
+}
+
+// Read 读取镜像数据
+func (s *ImageStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		defer s.mu.Unlock()
+		return 0, s.err
+	}
+	s.mu.Unlock()
+
+	n, err := s.stdout.Read(p)
+	if err == nil {
+		return n, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == io.EOF && !s.waited {
+		s.waited = true
+		if werr := s.cmd.Wait(); werr != nil {
+			s.err = fmt.Errorf("保存镜像失败: %w: %s", werr, strings.TrimSpace(s.stderr.String()))
+			return n, s.err
+		}
+	}
+	s.err = err
+	return n, err
+}
+
+// Close 结束 docker save，数据未读完时强制终止进程
+func (s *ImageStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel()
+	if !s.waited {
+		s.waited = true
+		// 先关闭读取端，仍在写入的进程会收到 EPIPE 退出
+		s.stdout.Close()
+		s.cmd.Wait()
+	}
+	return nil
+}
+
+// ImageSize 返回镜像的大小（字节），用于估算流式传输的进度，获取失败时返回 0
+func (c *Client) ImageSize(image string) int64 {
+	output, err := exec.Command("docker", "image", "inspect", "-f", "{{.Size}}", image).Output()
+	if err != nil {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
@@ -0,0 +1,225 @@
+package hostpattern
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// MaxHosts 单个模式最多展开的主机数量，防止误写导致展开出海量主机
+const MaxHosts = 4096
+
+// IsPattern 判断字符串是否包含范围或 CIDR 模式
+func IsPattern(s string) bool {
+	if strings.Contains(s, "/") {
+		return true
+	}
+	for _, part := range bracketParts(s) {
+		if part.isRange {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand 展开主机模式，不含模式的字符串原样返回
+//
+//	10.0.3.[10:49]        -> 10.0.3.10 ... 10.0.3.49
+//	node[01:20].dc1       -> node01.dc1 ... node20.dc1（保留前导零）
+//	web-[a:c]             -> web-a web-b web-c
+//	host[1:10:2]          -> host1 host3 ... host9（步长）
+//	10.0.5.0/28           -> 10.0.5.1 ... 10.0.5.14（不含网络地址和广播地址）
+//	deploy@10.0.3.[1:3]:2222 -> 用户名和端口会保留
+func Expand(s string) ([]string, error) {
+	if strings.Contains(s, "/") {
+		return expandCIDR(s)
+	}
+	return expandRanges(s)
+}
+
+// bracketPart 方括号拆分后的片段
+type bracketPart struct {
+	text    string
+	isRange bool
+}
+
+// bracketParts 按方括号拆分字符串，方括号内是 IP 地址（IPv6）时按普通文本处理
+func bracketParts(s string) []bracketPart {
+	var parts []bracketPart
+	for {
+		start := strings.Index(s, "[")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "]")
+		if end < 0 {
+			break
+		}
+		end += start
+
+		inner := s[start+1 : end]
+		if net.ParseIP(inner) != nil || !strings.Contains(inner, ":") {
+			parts = append(parts, bracketPart{text: s[:end+1]})
+		} else {
+			parts = append(parts, bracketPart{text: s[:start]}, bracketPart{text: inner, isRange: true})
+		}
+		s = s[end+1:]
+	}
+	return append(parts, bracketPart{text: s})
+}
+
+// expandRanges 展开 [start:end] 和 [start:end:step] 范围，多个范围做笛卡尔积
+func expandRanges(s string) ([]string, error) {
+	results := []string{""}
+	for _, part := range bracketParts(s) {
+		if !part.isRange {
+			for i := range results {
+				results[i] += part.text
+			}
+			continue
+		}
+
+		values, err := rangeValues(part.text)
+		if err != nil {
+			return nil, fmt.Errorf("主机范围 %s 无效: %w", s, err)
+		}
+		if len(results)*len(values) > MaxHosts {
+			return nil, fmt.Errorf("主机范围 %s 展开后超过 %d 台", s, MaxHosts)
+		}
+
+		next := make([]string, 0, len(results)*len(values))
+		for _, prefix := range results {
+			for _, v := range values {
+				next = append(next, prefix+v)
+			}
+		}
+		results = next
+	}
+	return results, nil
+}
+
+// rangeValues 展开单个范围，支持数字（保留前导零宽度）和单个字母
+func rangeValues(spec string) ([]string, error) {
+	fields := strings.Split(spec, ":")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("格式应为 start:end 或 start:end:step")
+	}
+
+	step := 1
+	if len(fields) == 3 {
+		var err error
+		step, err = strconv.Atoi(fields[2])
+		if err != nil || step <= 0 {
+			return nil, fmt.Errorf("步长无效: %s", fields[2])
+		}
+	}
+
+	startStr, endStr := fields[0], fields[1]
+
+	// 字母范围: [a:f]
+	if isLetter(startStr) && isLetter(endStr) {
+		start, end := startStr[0], endStr[0]
+		if start > end {
+			return nil, fmt.Errorf("起始值大于结束值")
+		}
+		var values []string
+		for c := int(start); c <= int(end); c += step {
+			values = append(values, string(rune(c)))
+		}
+		return values, nil
+	}
+
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("起始值无效: %s", startStr)
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("结束值无效: %s", endStr)
+	}
+	if start > end {
+		return nil, fmt.Errorf("起始值大于结束值")
+	}
+	if (end-start)/step+1 > MaxHosts {
+		return nil, fmt.Errorf("展开后超过 %d 台", MaxHosts)
+	}
+
+	// 起始值带前导零时按其宽度补零，如 [01:20]
+	width := 0
+	if len(startStr) > 1 && startStr[0] == '0' {
+		width = len(startStr)
+	}
+
+	values := make([]string, 0, (end-start)/step+1)
+	for i := start; i <= end; i += step {
+		values = append(values, fmt.Sprintf("%0*d", width, i))
+	}
+	return values, nil
+}
+
+// isLetter 判断是否为单个 ASCII 字母
+func isLetter(s string) bool {
+	return len(s) == 1 && (s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z')
+}
+
+// expandCIDR 展开 CIDR 网段，支持 user@ 前缀和 :port 后缀（IPv6 网段带端口时写成 [addr/prefix]:port）
+func expandCIDR(s string) ([]string, error) {
+	userPrefix := ""
+	rest := s
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		userPrefix, rest = rest[:i+1], rest[i+1:]
+	}
+
+	cidr, port := rest, ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("网段 %s 无效", s)
+		}
+		cidr = rest[1:end]
+		port = strings.TrimPrefix(rest[end+1:], ":")
+	} else if i := strings.LastIndex(rest, ":"); i >= 0 && strings.Count(rest, ":") == 1 {
+		cidr, port = rest[:i], rest[i+1:]
+	}
+
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("网段 %s 无效: %w", s, err)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	hostBits := bits - ones
+	if hostBits > 12 {
+		return nil, fmt.Errorf("网段 %s 过大，最多支持 %d 个地址", s, MaxHosts)
+	}
+	total := 1 << hostBits
+
+	// IPv4 网段（/31 和 /32 除外）不包含网络地址和广播地址
+	first, last := 0, total-1
+	isV4 := ip.To4() != nil
+	if isV4 && hostBits >= 2 {
+		first, last = 1, total-2
+	}
+
+	base := new(big.Int).SetBytes(ipNet.IP)
+	results := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		addr := bigToIP(new(big.Int).Add(base, big.NewInt(int64(i))), len(ipNet.IP))
+		host := addr.String()
+		if port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		results = append(results, userPrefix+host)
+	}
+	return results, nil
+}
+
+// bigToIP 将整数转换为指定长度的 IP 地址
+func bigToIP(n *big.Int, size int) net.IP {
+	b := n.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(b):], b)
+	return ip
+}
@@ -0,0 +1,83 @@
+package hostpattern
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"192.168.1.10", []string{"192.168.1.10"}},
+		{"web1.example.com", []string{"web1.example.com"}},
+		{"10.0.3.[10:12]", []string{"10.0.3.10", "10.0.3.11", "10.0.3.12"}},
+		{"node[08:10].dc1", []string{"node08.dc1", "node09.dc1", "node10.dc1"}},
+		{"web-[a:c]", []string{"web-a", "web-b", "web-c"}},
+		{"host[1:9:4]", []string{"host1", "host5", "host9"}},
+		{"r[1:2]n[a:b]", []string{"r1na", "r1nb", "r2na", "r2nb"}},
+		{"deploy@10.0.3.[1:2]:2222", []string{"deploy@10.0.3.1:2222", "deploy@10.0.3.2:2222"}},
+		// 方括号内是 IPv6 地址时不是范围
+		{"[fd00::10]:2222", []string{"[fd00::10]:2222"}},
+		{"root@[fd00::10]", []string{"root@[fd00::10]"}},
+		// CIDR: IPv4 不含网络地址和广播地址
+		{"10.0.5.0/30", []string{"10.0.5.1", "10.0.5.2"}},
+		{"10.0.5.7/29", []string{"10.0.5.1", "10.0.5.2", "10.0.5.3", "10.0.5.4", "10.0.5.5", "10.0.5.6"}},
+		{"10.0.5.4/31", []string{"10.0.5.4", "10.0.5.5"}},
+		{"10.0.5.9/32", []string{"10.0.5.9"}},
+		{"deploy@10.0.5.0/30:2222", []string{"deploy@10.0.5.1:2222", "deploy@10.0.5.2:2222"}},
+		// IPv6 网段包含全部地址，带端口时写成 [addr/prefix]:port
+		{"fd00::/127", []string{"fd00::", "fd00::1"}},
+		{"[fd00::/127]:2222", []string{"[fd00::]:2222", "[fd00::1]:2222"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Expand(tt.in)
+			if err != nil {
+				t.Fatalf("Expand(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expand(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	tests := []string{
+		"web[5:1]",         // 起始值大于结束值
+		"web[a:9]",         // 字母与数字混用
+		"web[1:3:0]",       // 步长无效
+		"web[1:2:3:4]",     // 字段过多
+		"web[0:5000]",      // 超过 MaxHosts
+		"a[0:99]b[0:99]",   // 笛卡尔积超过 MaxHosts
+		"10.0.0.0/8",       // 网段过大
+		"10.0.0.300/30",    // 地址无效
+		"[fd00::/127:2222", // 方括号不匹配
+		"fd00::/abc",       // 前缀无效
+	}
+	for _, in := range tests {
+		if _, err := Expand(in); err == nil {
+			t.Errorf("Expand(%q) 应返回错误", in)
+		}
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"web1", false},
+		{"[fd00::10]:22", false},
+		{"web[1:3]", true},
+		{"10.0.0.0/24", true},
+	}
+	for _, tt := range tests {
+		if got := IsPattern(tt.in); got != tt.want {
+			t.Errorf("IsPattern(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
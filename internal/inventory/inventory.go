@@ -0,0 +1,469 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"dockship/internal/hostpattern"
+)
+
+// Host 清单中的主机
+type Host struct {
+	Name string            // 清单中的主机名
+	Vars map[string]string // 合并后的主机变量（主机变量 > 子组变量 > 父组变量 > all）
+}
+
+// Group 清单中的主机组
+type Group struct {
+	Name     string
+	Hosts    []string          // 直接属于该组的主机
+	Children []string          // 子组
+	Vars     map[string]string // 组变量
+}
+
+// Inventory 解析后的 Ansible 主机清单
+type Inventory struct {
+	Hosts  []Host  // 按出现顺序排列的主机
+	Groups []Group // 按出现顺序排列的主机组
+
+	hostVars   map[string]map[string]string
+	hostOrder  []string
+	groups     map[string]*Group
+	groupOrder []string
+}
+
+// Load 读取 Ansible 主机清单，支持 INI 和 YAML 两种格式
+func Load(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取主机清单失败: %w", err)
+	}
+
+	inv := &Inventory{
+		hostVars: make(map[string]map[string]string),
+		groups:   make(map[string]*Group),
+	}
+	inv.group("all")
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml", ".json":
+		err = inv.parseYAML(data)
+	default:
+		err = inv.parseINI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析主机清单失败 [%s]: %w", path, err)
+	}
+
+	inv.finish()
+	return inv, nil
+}
+
+// GroupHosts 返回组内所有主机（包括子组中的主机）
+func (inv *Inventory) GroupHosts(name string) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	inv.walkGroup(name, make(map[string]bool), func(g *Group) {
+		for _, h := range g.Hosts {
+			if !seen[h] {
+				seen[h] = true
+				hosts = append(hosts, h)
+			}
+		}
+	})
+	return hosts
+}
+
+// walkGroup 深度优先遍历组及其子组，visited 用于避免循环引用
+func (inv *Inventory) walkGroup(name string, visited map[string]bool, fn func(*Group)) {
+	if visited[name] {
+		return
+	}
+	visited[name] = true
+	g, ok := inv.groups[name]
+	if !ok {
+		return
+	}
+	fn(g)
+	for _, child := range g.Children {
+		inv.walkGroup(child, visited, fn)
+	}
+}
+
+// group 获取或创建主机组
+func (inv *Inventory) group(name string) *Group {
+	if g, ok := inv.groups[name]; ok {
+		return g
+	}
+	g := &Group{Name: name, Vars: make(map[string]string)}
+	inv.groups[name] = g
+	inv.groupOrder = append(inv.groupOrder, name)
+	return g
+}
+
+// addHosts 将主机加入组，主机名中的范围（如 web[01:20]）会展开为多台主机
+func (inv *Inventory) addHosts(groupName, pattern string, vars map[string]string) error {
+	hosts, err := hostpattern.Expand(pattern)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		inv.addHost(groupName, host, vars)
+	}
+	return nil
+}
+
+// addHost 将主机加入组，并合并主机变量
+func (inv *Inventory) addHost(groupName, host string, vars map[string]string) {
+	if _, ok := inv.hostVars[host]; !ok {
+		inv.hostVars[host] = make(map[string]string)
+		inv.hostOrder = append(inv.hostOrder, host)
+	}
+	for k, v := range vars {
+		inv.hostVars[host][k] = v
+	}
+
+	g := inv.group(groupName)
+	for _, h := range g.Hosts {
+		if h == host {
+			return
+		}
+	}
+	g.Hosts = append(g.Hosts, host)
+}
+
+// addChild 添加子组
+func (inv *Inventory) addChild(parent, child string) {
+	g := inv.group(parent)
+	inv.group(child)
+	for _, c := range g.Children {
+		if c == child {
+			return
+		}
+	}
+	g.Children = append(g.Children, child)
+}
+
+// finish 建立 all/ungrouped 关系并计算每台主机的最终变量
+func (inv *Inventory) finish() {
+	// 没有父组的组都属于 all
+	hasParent := make(map[string]bool)
+	for _, g := range inv.groups {
+		for _, child := range g.Children {
+			hasParent[child] = true
+		}
+	}
+	for _, name := range inv.groupOrder {
+		if name != "all" && !hasParent[name] {
+			inv.addChild("all", name)
+		}
+	}
+
+	// 计算组深度，变量按 深度从小到大、同深度按组名 的顺序覆盖（与 Ansible 一致）
+	depth := make(map[string]int)
+	var setDepth func(name string, d int, path map[string]bool)
+	setDepth = func(name string, d int, path map[string]bool) {
+		if path[name] {
+			return
+		}
+		if cur, ok := depth[name]; ok && cur >= d {
+			return
+		}
+		depth[name] = d
+		path[name] = true
+		for _, child := range inv.groups[name].Children {
+			setDepth(child, d+1, path)
+		}
+		delete(path, name)
+	}
+	setDepth("all", 0, make(map[string]bool))
+
+	inv.Hosts = make([]Host, 0, len(inv.hostOrder))
+	for _, name := range inv.hostOrder {
+		var memberOf []string
+		for _, groupName := range inv.groupOrder {
+			for _, h := range inv.GroupHosts(groupName) {
+				if h == name {
+					memberOf = append(memberOf, groupName)
+					break
+				}
+			}
+		}
+		sort.SliceStable(memberOf, func(i, j int) bool {
+			if depth[memberOf[i]] != depth[memberOf[j]] {
+				return depth[memberOf[i]] < depth[memberOf[j]]
+			}
+			return memberOf[i] < memberOf[j]
+		})
+
+		vars := make(map[string]string)
+		for _, groupName := range memberOf {
+			for k, v := range inv.groups[groupName].Vars {
+				vars[k] = v
+			}
+		}
+		for k, v := range inv.hostVars[name] {
+			vars[k] = v
+		}
+		inv.Hosts = append(inv.Hosts, Host{Name: name, Vars: vars})
+	}
+
+	inv.Groups = make([]Group, 0, len(inv.groupOrder))
+	for _, name := range inv.groupOrder {
+		inv.Groups = append(inv.Groups, *inv.groups[name])
+	}
+}
+
+// parseINI 解析 INI 格式的主机清单
+func (inv *Inventory) parseINI(data []byte) error {
+	section, kind := "ungrouped", "hosts"
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		// [group] / [group:vars] / [group:children]
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := line[1 : len(line)-1]
+			section, kind = name, "hosts"
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				section, kind = name[:i], name[i+1:]
+			}
+			switch kind {
+			case "hosts", "vars", "children":
+			default:
+				return fmt.Errorf("第 %d 行: 不支持的段类型 %s", lineNum, kind)
+			}
+			inv.group(section)
+			continue
+		}
+
+		fields, err := splitFields(line)
+		if err != nil {
+			return fmt.Errorf("第 %d 行: %w", lineNum, err)
+		}
+
+		switch kind {
+		case "hosts":
+			vars := make(map[string]string)
+			for _, field := range fields[1:] {
+				k, v, ok := strings.Cut(field, "=")
+				if !ok {
+					return fmt.Errorf("第 %d 行: 主机变量格式错误: %s", lineNum, field)
+				}
+				vars[k] = v
+			}
+			if err := inv.addHosts(section, fields[0], vars); err != nil {
+				return fmt.Errorf("第 %d 行: %w", lineNum, err)
+			}
+		case "vars":
+			k, v, ok := strings.Cut(stripComment(line), "=")
+			if !ok {
+				return fmt.Errorf("第 %d 行: 组变量格式错误: %s", lineNum, line)
+			}
+			inv.group(section).Vars[strings.TrimSpace(k)] = unquote(strings.TrimSpace(v))
+		case "children":
+			inv.addChild(section, fields[0])
+		}
+	}
+	return scanner.Err()
+}
+
+// splitFields 按空白拆分，支持单双引号，并去掉行尾注释
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	var quote rune
+	hasField := false
+
+	for _, r := range stripComment(line) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			hasField = true
+		case r == ' ' || r == '\t':
+			if hasField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				hasField = false
+			}
+		default:
+			cur.WriteRune(r)
+			hasField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("引号不匹配")
+	}
+	if hasField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+// stripComment 去掉行尾注释：引号之外、位于行首或空白之后的 # 或 ; 开始注释，a=b#c 中的 # 属于值
+func stripComment(line string) string {
+	var quote rune
+	afterSpace := true
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case (r == '#' || r == ';') && afterSpace:
+			return strings.TrimSpace(line[:i])
+		}
+		afterSpace = r == ' ' || r == '\t'
+	}
+	return line
+}
+
+// unquote 去掉值两端成对的引号
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseYAML 解析 YAML 格式的主机清单
+//
+//	all:
+//	  hosts:
+//	    web1:
+//	      ansible_host: 10.0.0.1
+//	  children:
+//	    db:
+//	      hosts:
+//	        db1:
+//	      vars:
+//	        ansible_user: postgres
+func (inv *Inventory) parseYAML(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("清单的顶层必须是组的映射")
+	}
+
+	// 按文档中的顺序解析组和主机，与 INI 格式一致
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if err := inv.parseYAMLGroup(root.Content[i].Value, root.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseYAMLGroup 解析 YAML 清单中的一个组
+func (inv *Inventory) parseYAMLGroup(name string, node *yaml.Node) error {
+	g := inv.group(name)
+	node = resolveAlias(node)
+	if isNull(node) {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("组 %s 的格式错误", name)
+	}
+
+	if hosts := mappingValue(node, "hosts"); hosts != nil && hosts.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(hosts.Content); i += 2 {
+			host := hosts.Content[i].Value
+			vars, err := stringVars(hosts.Content[i+1])
+			if err != nil {
+				return fmt.Errorf("主机 %s: %w", host, err)
+			}
+			if err := inv.addHosts(name, host, vars); err != nil {
+				return err
+			}
+		}
+	}
+
+	if vars := mappingValue(node, "vars"); vars != nil {
+		parsed, err := stringVars(vars)
+		if err != nil {
+			return fmt.Errorf("组 %s: %w", name, err)
+		}
+		for k, v := range parsed {
+			g.Vars[k] = v
+		}
+	}
+
+	if children := mappingValue(node, "children"); children != nil && children.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(children.Content); i += 2 {
+			child := children.Content[i].Value
+			inv.addChild(name, child)
+			if err := inv.parseYAMLGroup(child, children.Content[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stringVars 将 YAML 变量转换为字符串映射，复杂类型和空值会被忽略
+func stringVars(node *yaml.Node) (map[string]string, error) {
+	vars := make(map[string]string)
+	node = resolveAlias(node)
+	if isNull(node) {
+		return vars, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("变量必须是映射")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := resolveAlias(node.Content[i+1])
+		if value.Kind == yaml.ScalarNode && !isNull(value) {
+			vars[node.Content[i].Value] = value.Value
+		}
+	}
+	return vars, nil
+}
+
+// mappingValue 返回映射中键对应的值，不存在时返回 nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// resolveAlias 返回别名（*anchor）指向的节点
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// isNull 判断节点是否为空值（如只写了主机名的 web1:）
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
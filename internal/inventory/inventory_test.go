@@ -0,0 +1,239 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// load 将清单内容写入临时文件（扩展名决定格式）并解析
+func load(t *testing.T, name, content string) *Inventory {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入 %s: %v", name, err)
+	}
+	inv, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%s): %v", name, err)
+	}
+	return inv
+}
+
+// hostVars 返回主机的最终变量
+func hostVars(t *testing.T, inv *Inventory, name string) map[string]string {
+	t.Helper()
+	for _, host := range inv.Hosts {
+		if host.Name == name {
+			return host.Vars
+		}
+	}
+	t.Fatalf("主机清单中没有主机 %s", name)
+	return nil
+}
+
+const iniInventory = `
+# 未分组的主机
+bastion ansible_host=203.0.113.1
+
+[web]
+web[01:03].dc1 ansible_port=2222
+web-legacy ansible_host="10.0.0.9" # 行尾注释
+; 分号开头的整行是注释
+
+[db]
+db1 ansible_host=10.0.2.1 ansible_user=postgres
+
+[prod:children]
+web
+db
+
+[prod:vars]
+ansible_user = deploy ; 行尾注释
+env='prod' # 行尾注释
+motd="a # b"
+
+[web:vars]
+ansible_user=www
+
+[all:vars]
+ansible_user=root
+ansible_port=22 # ssh
+`
+
+func TestINI(t *testing.T) {
+	inv := load(t, "hosts", iniInventory)
+
+	var names []string
+	for _, host := range inv.Hosts {
+		names = append(names, host.Name)
+	}
+	wantNames := []string{"bastion", "web01.dc1", "web02.dc1", "web03.dc1", "web-legacy", "db1"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Hosts = %v, want %v", names, wantNames)
+	}
+
+	groups := []struct {
+		name  string
+		hosts []string
+	}{
+		{"ungrouped", []string{"bastion"}},
+		{"web", []string{"web01.dc1", "web02.dc1", "web03.dc1", "web-legacy"}},
+		{"prod", []string{"web01.dc1", "web02.dc1", "web03.dc1", "web-legacy", "db1"}},
+		{"all", wantNames},
+	}
+	for _, g := range groups {
+		if got := inv.GroupHosts(g.name); !reflect.DeepEqual(got, g.hosts) {
+			t.Errorf("GroupHosts(%q) = %v, want %v", g.name, got, g.hosts)
+		}
+	}
+}
+
+func TestINIVarPrecedence(t *testing.T) {
+	inv := load(t, "hosts.ini", iniInventory)
+
+	tests := []struct {
+		host string
+		want map[string]string
+	}{
+		// 主机变量 > 子组变量（web）> 父组变量（prod）> all
+		{"web01.dc1", map[string]string{"ansible_user": "www", "ansible_port": "2222", "env": "prod", "motd": "a # b"}},
+		{"web-legacy", map[string]string{"ansible_user": "www", "ansible_port": "22", "env": "prod", "motd": "a # b", "ansible_host": "10.0.0.9"}},
+		{"db1", map[string]string{"ansible_user": "postgres", "ansible_port": "22", "env": "prod", "motd": "a # b", "ansible_host": "10.0.2.1"}},
+		{"bastion", map[string]string{"ansible_user": "root", "ansible_port": "22", "ansible_host": "203.0.113.1"}},
+	}
+	for _, tt := range tests {
+		if got := hostVars(t, inv, tt.host); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("主机 %s 的变量 = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestINIGroupVarComments(t *testing.T) {
+	inv := load(t, "hosts", iniInventory)
+	for _, g := range inv.Groups {
+		if g.Name != "prod" {
+			continue
+		}
+		want := map[string]string{"ansible_user": "deploy", "env": "prod", "motd": "a # b"}
+		if !reflect.DeepEqual(g.Vars, want) {
+			t.Errorf("prod 组变量 = %v, want %v", g.Vars, want)
+		}
+		return
+	}
+	t.Fatal("主机清单中没有组 prod")
+}
+
+func TestINIErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown section": "[web:other]\nweb1\n",
+		"bad host var":    "[web]\nweb1 ansible_port\n",
+		"bad group var":   "[web:vars]\nansible_user\n",
+		"unclosed quote":  "[web]\nweb1 ansible_host=\"10.0.0.1\n",
+		"bad range":       "[web]\nweb[05:01]\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hosts")
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Errorf("Load 应返回错误")
+			}
+		})
+	}
+}
+
+const yamlInventory = `
+all:
+  hosts:
+    bastion:
+      ansible_host: 203.0.113.1
+  vars:
+    ansible_user: root
+    ansible_port: 22
+  children:
+    prod:
+      vars:
+        ansible_user: deploy
+      children:
+        web:
+          hosts:
+            web[1:2]:
+              ansible_port: 2222
+          vars:
+            ansible_user: www
+            debug: true
+        db:
+          hosts:
+            db1:
+              ansible_host: 10.0.2.1
+              tags: [a, b]
+`
+
+func TestYAML(t *testing.T) {
+	inv := load(t, "hosts.yml", yamlInventory)
+
+	// 组和主机按文档中的顺序排列
+	var groups []string
+	for _, g := range inv.Groups {
+		groups = append(groups, g.Name)
+	}
+	if want := []string{"all", "prod", "web", "db"}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("Groups = %v, want %v", groups, want)
+	}
+	if got, want := inv.GroupHosts("prod"), []string{"web1", "web2", "db1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupHosts(prod) = %v, want %v", got, want)
+	}
+	if got, want := inv.GroupHosts("all"), []string{"bastion", "web1", "web2", "db1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupHosts(all) = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		host string
+		want map[string]string
+	}{
+		{"web1", map[string]string{"ansible_user": "www", "ansible_port": "2222", "debug": "true"}},
+		// 复杂类型的变量被忽略
+		{"db1", map[string]string{"ansible_user": "deploy", "ansible_port": "22", "ansible_host": "10.0.2.1"}},
+		{"bastion", map[string]string{"ansible_user": "root", "ansible_port": "22", "ansible_host": "203.0.113.1"}},
+	}
+	for _, tt := range tests {
+		if got := hostVars(t, inv, tt.host); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("主机 %s 的变量 = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGroupCycle(t *testing.T) {
+	inv := load(t, "hosts", "[a:children]\nb\n\n[b:children]\na\n\n[b]\nhost1\n")
+	if got, want := inv.GroupHosts("a"), []string{"host1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupHosts(a) = %v, want %v", got, want)
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"web1 a=1  b=2", []string{"web1", "a=1", "b=2"}},
+		{`web1 msg="hello world" x='y z'`, []string{"web1", "msg=hello world", "x=y z"}},
+		{"web1 a=1 # 注释", []string{"web1", "a=1"}},
+		{"web1 a=b#c", []string{"web1", "a=b#c"}},
+		{"web1 a=1 ; 注释", []string{"web1", "a=1"}},
+		{`web1 msg="x # y"`, []string{"web1", "msg=x # y"}},
+	}
+	for _, tt := range tests {
+		got, err := splitFields(tt.line)
+		if err != nil {
+			t.Errorf("splitFields(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitFields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// 认证方式
+const (
+	AuthMethodAgent    = "agent"    // ssh-agent（SSH_AUTH_SOCK）
+	AuthMethodKey      = "key"      // 私钥文件
+	AuthMethodPassword = "password" // 密码
+
+	AuthMethodKeyboardInteractive = "keyboard-interactive" // 键盘交互（PAM 密码、OTP 验证码等）
+)
+
+// AuthConfig 认证参数
+type AuthConfig struct {
+	Methods   []string // 按顺序尝试的认证方式
+	KeyFiles  []string // 私钥文件列表
+	CertFiles []string // 用户证书文件列表，未配置时自动查找 <私钥>-cert.pub
+	Password  string   // SSH密码
+	Keyring   *Keyring // 共享的私钥和 ssh-agent 连接，为 nil 时认证器单独使用一份
+}
+
+// Authenticator SSH认证器，在使用相同凭据的所有主机连接间共享
+// 私钥和 ssh-agent 连接来自 Keyring，不同凭据组合的认证器之间也只解析（解密）一次
+type Authenticator struct {
+	cfg AuthConfig
+
+	once       sync.Once
+	err        error
+	agent      agent.ExtendedAgent
+	keySigners []ssh.Signer
+	certs      []*userCert
+}
+
+// NewAuthenticator 创建SSH认证器
+func NewAuthenticator(cfg AuthConfig) *Authenticator {
+	if cfg.Keyring == nil {
+		cfg.Keyring = NewKeyring("", "")
+	}
+	return &Authenticator{cfg: cfg}
+}
+
+// Load 连接 ssh-agent 并解析私钥文件，多次调用只执行一次
+func (a *Authenticator) Load() error {
+	a.once.Do(func() {
+		a.err = a.load()
+	})
+	return a.err
+}
+
+func (a *Authenticator) load() error {
+	for _, method := range a.cfg.Methods {
+		switch method {
+		case AuthMethodAgent:
+			if agent, err := a.cfg.Keyring.Agent(); err == nil {
+				a.agent = agent
+			}
+		case AuthMethodKey:
+			for _, keyFile := range a.cfg.KeyFiles {
+				signer, err := a.cfg.Keyring.Signer(keyFile)
+				if err != nil {
+					return err
+				}
+				a.keySigners = append(a.keySigners, signer)
+			}
+		case AuthMethodPassword, AuthMethodKeyboardInteractive:
+		default:
+			return fmt.Errorf("不支持的SSH认证方式: %s", method)
+		}
+	}
+
+	if err := a.loadCerts(); err != nil {
+		return err
+	}
+
+	if len(a.methods("", nil)) == 0 {
+		return fmt.Errorf("没有可用的SSH认证方式（auth_methods: %v）", a.cfg.Methods)
+	}
+	return nil
+}
+
+// loadCerts 加载用户证书，在连接任何主机之前检查证书有效期
+// 显式配置的证书无效时返回错误；自动找到的 <私钥>-cert.pub 无效时输出警告并跳过
+func (a *Authenticator) loadCerts() error {
+	if a.agent == nil && len(a.keySigners) == 0 {
+		return nil
+	}
+
+	// 显式配置的证书在前，之后是与私钥同名的 <私钥>-cert.pub
+	certFiles := append([]string{}, a.cfg.CertFiles...)
+	explicit := len(certFiles)
+	for _, keyFile := range a.cfg.KeyFiles {
+		certFile := CertFileFor(keyFile)
+		if _, err := os.Stat(certFile); err == nil && !containsString(certFiles, certFile) {
+			certFiles = append(certFiles, certFile)
+		}
+	}
+
+	for i, certFile := range certFiles {
+		cert, err := loadCertFile(certFile)
+		if err != nil {
+			if i < explicit {
+				return err
+			}
+			// 自动找到的证书过期或无法读取时不影响使用私钥本身认证
+			fmt.Printf("⚠️  跳过SSH证书，改用私钥认证: %v\n", err)
+			continue
+		}
+		// 显式配置的证书必须与某个私钥匹配；使用 ssh-agent 时私钥可能只在 agent 中
+		if i < explicit && a.agent == nil && !cert.matchesAny(a.keySigners) {
+			return fmt.Errorf("SSH证书 %s 与配置的私钥均不匹配", certFile)
+		}
+		fmt.Printf("🪪 使用SSH证书: %s\n", cert.describe())
+		a.certs = append(a.certs, cert)
+	}
+	return nil
+}
+
+// AuthMethods 返回连接指定主机时按配置顺序排列的认证方式，out 为交互提示的输出位置（见 readSecret）
+// agent 与私钥同属 publickey 认证，会合并为一个认证方式并保持相对顺序
+func (a *Authenticator) AuthMethods(host string, out io.Writer) ([]ssh.AuthMethod, error) {
+	if err := a.Load(); err != nil {
+		return nil, err
+	}
+	return a.methods(host, out), nil
+}
+
+func (a *Authenticator) methods(host string, out io.Writer) []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+	publicKeyAdded := false
+
+	for _, method := range a.cfg.Methods {
+		switch method {
+		case AuthMethodAgent, AuthMethodKey:
+			if publicKeyAdded || !a.hasPublicKeys() {
+				continue
+			}
+			methods = append(methods, ssh.PublicKeysCallback(a.signers))
+			publicKeyAdded = true
+		case AuthMethodPassword:
+			if a.cfg.Password != "" {
+				methods = append(methods, ssh.Password(a.cfg.Password))
+			}
+		case AuthMethodKeyboardInteractive:
+			methods = append(methods, ssh.KeyboardInteractive(a.keyboardInteractive(host, out)))
+		}
+	}
+	return methods
+}
+
+// hasPublicKeys 是否存在可用于 publickey 认证的密钥来源
+func (a *Authenticator) hasPublicKeys() bool {
+	return a.agent != nil || len(a.keySigners) > 0
+}
+
+// signers 按配置顺序返回 agent 和私钥文件中的签名器
+func (a *Authenticator) signers() ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	for _, method := range a.cfg.Methods {
+		switch method {
+		case AuthMethodAgent:
+			if a.agent == nil {
+				continue
+			}
+			agentSigners, err := a.agent.Signers()
+			if err != nil {
+				fmt.Printf("⚠️  读取 ssh-agent 密钥失败: %v\n", err)
+				continue
+			}
+			signers = append(signers, agentSigners...)
+		case AuthMethodKey:
+			signers = append(signers, a.keySigners...)
+		}
+	}
+	return a.withCerts(signers), nil
+}
+
+// withCerts 为有匹配证书的密钥添加证书签名器，证书优先于原始公钥尝试（与 OpenSSH 一致）
+func (a *Authenticator) withCerts(signers []ssh.Signer) []ssh.Signer {
+	if len(a.certs) == 0 {
+		return signers
+	}
+
+	result := make([]ssh.Signer, 0, len(signers)+len(a.certs))
+	for _, signer := range signers {
+		// agent 中可能已经有证书，不再重复包装
+		if _, isCert := signer.PublicKey().(*ssh.Certificate); !isCert {
+			for _, cert := range a.certs {
+				if !cert.matches(signer.PublicKey()) {
+					continue
+				}
+				certSigner, err := ssh.NewCertSigner(cert.cert, signer)
+				if err != nil {
+					fmt.Printf("⚠️  创建证书签名器失败 [%s]: %v\n", cert.file, err)
+					continue
+				}
+				result = append(result, certSigner)
+			}
+		}
+		result = append(result, signer)
+	}
+	return result
+}
+
+// containsString 判断列表中是否包含字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 提权方式
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+)
+
+// sudoPrompt sudo 的口令提示标记，用于判断 sudo 何时在等待口令
+const sudoPrompt = "[dockship-sudo-password]"
+
+// suPromptPattern su 的口令提示（不同语言环境下提示不同）
+var suPromptPattern = regexp.MustCompile(`(?i)(password|密码)\s*[:：]\s*$`)
+
+// Become 远程命令的提权配置
+type Become struct {
+	Method   string // 提权方式: sudo/su，为空时不提权
+	User     string // 提权后的用户
+	Password string // 提权密码，只通过标准输入发送
+	Hooks    bool   // hooks 是否也提权执行
+}
+
+// ExecutePrivileged 以提权方式执行远程命令，未配置提权时与 ExecuteCommand 相同
+func (c *Client) ExecutePrivileged(command string) (string, error) {
+	switch c.become.Method {
+	case "":
+		return c.ExecuteCommand(command)
+	case BecomeSudo:
+		return c.executeSudo(command)
+	case BecomeSu:
+		return c.executeSu(command)
+	default:
+		return "", fmt.Errorf("不支持的提权方式: %s", c.become.Method)
+	}
+}
+
+// executeSudo 通过 sudo 执行命令
+// 配置了密码时使用 -S 从标准输入读取，只在 sudo 实际提示时才发送；未配置密码时使用 -n，需要密码时直接失败
+func (c *Client) executeSudo(command string) (string, error) {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	output, prompts, err := runWithPassword(session, c.sudoCommand(command), c.become.Password, func(out []byte) bool {
+		return bytes.HasSuffix(out, []byte(sudoPrompt))
+	}, sudoPrompt)
+	if err != nil {
+		return output, explainSudoError(output, prompts, err)
+	}
+	return output, nil
+}
+
+// sudoCommand 生成 sudo 命令行，配置了密码时从标准输入读取口令并使用固定的提示标记
+func (c *Client) sudoCommand(command string) string {
+	if c.become.Password != "" {
+		return fmt.Sprintf("sudo -S -p %s -H -u %s -- sh -c %s", shellQuote(sudoPrompt), shellQuote(c.become.User), shellQuote(command))
+	}
+	return fmt.Sprintf("sudo -n -H -u %s -- sh -c %s", shellQuote(c.become.User), shellQuote(command))
+}
+
+// executeSu 通过 su 执行命令，su 只从终端读取密码，因此需要分配伪终端
+func (c *Client) executeSu(command string) (string, error) {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	modes := ssh.TerminalModes{ssh.ECHO: 0}
+	if err := session.RequestPty("xterm", 40, 200, modes); err != nil {
+		return "", fmt.Errorf("su 需要伪终端，请求伪终端失败: %w", err)
+	}
+
+	suCmd := fmt.Sprintf("su %s -c %s", shellQuote(c.become.User), shellQuote(command))
+	output, prompts, err := runWithPassword(session, suCmd, c.become.Password, suPromptPattern.Match, "")
+	if err != nil {
+		if prompts > 1 || strings.Contains(strings.ToLower(output), "authentication failure") {
+			return output, fmt.Errorf("su 认证失败，请检查 become 密码: %w", err)
+		}
+		return output, fmt.Errorf("su 执行失败: %w", err)
+	}
+	return output, nil
+}
+
+// runWithPassword 执行命令，输出中出现口令提示时通过标准输入发送密码（只发送一次）
+// 返回去掉提示后的合并输出和出现提示的次数
+func runWithPassword(session *ssh.Session, command, password string, isPrompt func([]byte) bool, marker string) (string, int, error) {
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", 0, fmt.Errorf("创建标准输入失败: %w", err)
+	}
+
+	w := &promptWatcher{stdin: stdin, password: password, isPrompt: isPrompt, marker: marker}
+	session.Stdout = w
+	session.Stderr = w
+
+	err = session.Run(command)
+	stdin.Close()
+	return w.String(), w.prompts, err
+}
+
+// promptWatcher 收集命令输出，并在出现口令提示时写入密码
+type promptWatcher struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	stdin    io.WriteCloser
+	password string
+	isPrompt func([]byte) bool
+	marker   string
+	prompts  int
+}
+
+func (w *promptWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	if !w.isPrompt(bytes.TrimRight(w.buf.Bytes(), " ")) {
+		return len(p), nil
+	}
+
+	w.prompts++
+	if w.marker != "" {
+		w.buf.Truncate(w.buf.Len() - len(w.marker))
+	}
+	if w.prompts == 1 && w.password != "" {
+		fmt.Fprintf(w.stdin, "%s\n", w.password)
+		// sudo -S 只读取一行口令，之后关闭标准输入，避免被提权的命令等待输入
+		if w.marker != "" {
+			w.stdin.Close()
+		}
+	} else {
+		// 密码错误或未配置密码，关闭标准输入让命令失败退出
+		w.stdin.Close()
+	}
+	return len(p), nil
+}
+
+func (w *promptWatcher) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(w.buf.String())
+}
+
+// explainSudoError 根据 sudo 的输出解释失败原因
+func explainSudoError(output string, prompts int, err error) error {
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "you must have a tty") || strings.Contains(lower, "a terminal is required"):
+		return fmt.Errorf("sudo 需要 TTY（sudoers 中启用了 requiretty），请为该用户关闭 requiretty 或改用 become.method: su: %w", err)
+	case strings.Contains(lower, "a password is required"):
+		return fmt.Errorf("sudo 需要密码，请配置 become.pwd 或 become.password_env，或在 sudoers 中为该用户配置 NOPASSWD: %w", err)
+	case prompts > 1 || strings.Contains(lower, "incorrect password") || strings.Contains(lower, "sorry, try again"):
+		return fmt.Errorf("sudo 密码错误，请检查 become 密码: %w", err)
+	case strings.Contains(lower, "not in the sudoers") || strings.Contains(lower, "not allowed to execute"):
+		return fmt.Errorf("当前用户没有 sudo 权限: %w", err)
+	case strings.Contains(lower, "sudo: command not found") || strings.Contains(lower, "sudo: not found"):
+		return fmt.Errorf("远程主机未安装 sudo: %w", err)
+	}
+	return fmt.Errorf("执行远程命令失败（sudo）: %w", err)
+}
+
+// shellQuote 使用单引号转义 shell 参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
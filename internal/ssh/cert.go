@@ -0,0 +1,88 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// userCert 用户证书
+type userCert struct {
+	file string
+	cert *ssh.Certificate
+}
+
+// loadCertFile 读取并校验 OpenSSH 用户证书（*-cert.pub）
+func loadCertFile(certFile string) (*userCert, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取SSH证书失败: %w", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析SSH证书失败 [%s]: %w", certFile, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("文件不是SSH证书 [%s]", certFile)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("SSH证书 %s 不是用户证书", certFile)
+	}
+	if err := checkValidity(cert, time.Now()); err != nil {
+		return nil, fmt.Errorf("SSH证书 %s %w", certFile, err)
+	}
+	return &userCert{file: certFile, cert: cert}, nil
+}
+
+// checkValidity 检查证书是否在有效期内
+func checkValidity(cert *ssh.Certificate, now time.Time) error {
+	unix := uint64(now.Unix())
+	if unix < cert.ValidAfter {
+		return fmt.Errorf("尚未生效（生效时间 %s）", formatCertTime(cert.ValidAfter))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && unix >= cert.ValidBefore {
+		return fmt.Errorf("已过期（过期时间 %s），请重新签发", formatCertTime(cert.ValidBefore))
+	}
+	return nil
+}
+
+// matches 判断证书是否属于该公钥
+func (c *userCert) matches(pub ssh.PublicKey) bool {
+	return bytes.Equal(c.cert.Key.Marshal(), pub.Marshal())
+}
+
+// matchesAny 判断证书是否属于任一签名器
+func (c *userCert) matchesAny(signers []ssh.Signer) bool {
+	for _, signer := range signers {
+		if c.matches(signer.PublicKey()) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe 返回证书的身份和有效期描述
+func (c *userCert) describe() string {
+	expires := "永久有效"
+	if c.cert.ValidBefore != ssh.CertTimeInfinity {
+		expires = "有效期至 " + formatCertTime(c.cert.ValidBefore)
+	}
+	return fmt.Sprintf("%s（ID: %s，%s）", c.file, c.cert.KeyId, expires)
+}
+
+// formatCertTime 格式化证书中的时间戳
+func formatCertTime(ts uint64) string {
+	if ts > uint64(1<<63-1) {
+		return "永久"
+	}
+	return time.Unix(int64(ts), 0).Format("2006-01-02 15:04:05")
+}
+
+// CertFileFor 返回私钥对应的默认证书路径（与 OpenSSH 一致: <私钥>-cert.pub）
+func CertFileFor(keyFile string) string {
+	return keyFile + "-cert.pub"
+}
@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch 远程文件的 SHA-256 与上传的数据不一致
+var ErrChecksumMismatch = errors.New("校验和不一致")
+
+// VerifyChecksum 校验远程文件的 SHA-256 是否为 expected（十六进制）
+// 优先在远程执行 sha256sum；远程没有 sha256sum 时通过 SFTP 读回整个文件在本地计算
+func (c *Client) VerifyChecksum(remotePath, expected string) error {
+	actual, err := c.remoteFileHash(remotePath)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("远程文件 %s 的 SHA-256 为 %s，期望 %s: %w", remotePath, actual, expected, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// VerifyChecksumInPlace 与 VerifyChecksum 相同，但只在远程执行 sha256sum，远程没有 sha256sum 时返回错误而不读回文件
+// 用于只是为了节省流量的场合（如确认二进制增量的基准文件），读回整个文件反而得不偿失
+func (c *Client) VerifyChecksumInPlace(remotePath, expected string) error {
+	output, err := c.ExecuteCommand("sha256sum " + shellQuote(remotePath))
+	if err != nil {
+		return fmt.Errorf("远程执行 sha256sum 失败: %w: %s", err, strings.TrimSpace(output))
+	}
+	actual, err := parseSHA256(output)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("远程文件 %s 的 SHA-256 为 %s，期望 %s: %w", remotePath, actual, expected, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// remoteFileHash 计算整个远程文件的 SHA-256
+func (c *Client) remoteFileHash(remotePath string) (string, error) {
+	output, err := c.ExecuteCommand("sha256sum " + shellQuote(remotePath))
+	if err == nil {
+		return parseSHA256(output)
+	}
+	if c.sftpClient == nil {
+		return "", fmt.Errorf("计算远程文件校验和失败（SFTP 不可用，无法读回校验；可设置 transfer.verify_checksum: false 关闭校验）: %w: %s", err, strings.TrimSpace(output))
+	}
+	return c.sftpHash(remotePath, 0, -1)
+}
+
+// sftpHash 通过 SFTP 读回远程文件从 offset 开始 length 字节（小于 0 表示到文件末尾），在本地计算 SHA-256
+func (c *Client) sftpHash(remotePath string, offset, length int64) (string, error) {
+	file, err := c.sftpClient.Open(remotePath)
+	if err != nil {
+		return "", fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if offset > 0 || length >= 0 {
+		if length < 0 {
+			info, err := file.Stat()
+			if err != nil {
+				return "", fmt.Errorf("获取远程文件信息失败: %w", err)
+			}
+			length = info.Size() - offset
+		}
+		r = io.NewSectionReader(file, offset, length)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("读取远程文件失败: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseSHA256 解析 sha256sum 的输出
+func parseSHA256(output string) (string, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("解析远程校验和失败: %q", strings.TrimSpace(output))
+	}
+	return fields[0], nil
+}
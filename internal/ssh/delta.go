@@ -0,0 +1,169 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dockship/internal/delta"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// unameArch uname -m 输出到 GOARCH 的映射
+var unameArch = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"i386":    "386",
+	"i686":    "386",
+	"armv7l":  "arm",
+	"armv6l":  "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// ErrHelperTooLarge 辅助程序比可能节省的流量还大，上传不划算
+var ErrHelperTooLarge = errors.New("辅助程序大于可能节省的流量")
+
+// InstallHelper 将本机的 dockship 程序上传到远程 dir 作为增量辅助程序，返回远程路径
+// 辅助程序按内容命名，已上传过的不再重复上传；尚未上传且程序大于 saving 时返回 ErrHelperTooLarge，
+// 远程系统或架构与本机不同、或程序无法执行时返回错误
+func (c *Client) InstallHelper(dir string, saving int64, progress *mpb.Progress) (string, error) {
+	output, err := c.ExecuteCommand("uname -sm")
+	if err != nil {
+		return "", fmt.Errorf("获取远程平台失败: %w", err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("无法识别远程平台: %q", strings.TrimSpace(output))
+	}
+	goos, goarch := strings.ToLower(fields[0]), unameArch[fields[1]]
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return "", fmt.Errorf("远程平台 %s/%s 与本机 %s/%s 不同，无法运行辅助程序", goos, fields[1], runtime.GOOS, runtime.GOARCH)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取本机程序路径失败: %w", err)
+	}
+	sum, size, err := fileDigest(exe)
+	if err != nil {
+		return "", err
+	}
+	helper := path.Join(dir, "dockship-helper-"+sum[:12])
+
+	if remoteSize, err := c.remoteFileSize(helper); err != nil || remoteSize != size {
+		if size > saving {
+			return "", fmt.Errorf("%w（%.2f MB > %.2f MB）", ErrHelperTooLarge, float64(size)/(1<<20), float64(saving)/(1<<20))
+		}
+		fmt.Fprintf(progress, "  📦 [%s] 上传增量辅助程序: %s（%.2f MB，每个版本只上传一次）\n", c.host, helper, float64(size)/(1<<20))
+		tmp := fmt.Sprintf("%s.%d.tmp", helper, time.Now().UnixNano())
+		if err := c.UploadFile(exe, tmp, progress); err != nil {
+			c.RemoveRemoteFile(tmp)
+			return "", fmt.Errorf("上传辅助程序失败: %w", err)
+		}
+		if output, err := c.ExecuteCommand(fmt.Sprintf("chmod 755 %[1]s && mv -f %[1]s %[2]s", shellQuote(tmp), shellQuote(helper))); err != nil {
+			return "", fmt.Errorf("安装辅助程序失败: %w: %s", err, strings.TrimSpace(output))
+		}
+	}
+
+	// 远程目录可能以 noexec 挂载
+	if output, err := c.ExecuteCommand(shellQuote(helper) + " delta --help"); err != nil {
+		return "", fmt.Errorf("远程无法执行辅助程序: %w: %s", err, strings.TrimSpace(output))
+	}
+	return helper, nil
+}
+
+// DeltaSignature 通过远程辅助程序计算基准文件的块签名
+func (c *Client) DeltaSignature(helper, basis string) (*delta.Signature, error) {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err := session.Start(fmt.Sprintf("%s delta signature %s", shellQuote(helper), shellQuote(basis))); err != nil {
+		return nil, fmt.Errorf("计算远程签名失败: %w", err)
+	}
+
+	sig, readErr := delta.ReadSignature(stdout)
+	io.Copy(io.Discard, stdout)
+	if err := session.Wait(); err != nil {
+		return nil, fmt.Errorf("计算远程签名失败: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("计算远程签名失败: %w", readErr)
+	}
+	return sig, nil
+}
+
+// DeltaPatch 通过远程辅助程序将增量应用到基准文件，在远程重建新文件
+func (c *Client) DeltaPatch(helper, basis, deltaPath, outPath string) error {
+	command := fmt.Sprintf("%s delta patch %s %s %s", shellQuote(helper), shellQuote(basis), shellQuote(deltaPath), shellQuote(outPath))
+	if output, err := c.ExecuteCommand(command); err != nil {
+		return fmt.Errorf("远程重建镜像文件失败: %w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// RunScript 将脚本上传到 scriptPath 后用 sh 执行，执行完删除脚本
+func (c *Client) RunScript(script, scriptPath string, progress *mpb.Progress) error {
+	if err := c.UploadReader(strings.NewReader(script), int64(len(script)), scriptPath, progress); err != nil {
+		return err
+	}
+	defer c.RemoveRemoteFile(scriptPath)
+
+	if output, err := c.ExecuteCommand("sh " + shellQuote(scriptPath)); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// LinkFile 将远程文件 src 保存为 dst：优先硬链接，不在同一文件系统时复制
+// 先写入临时文件再改名，dst 不会处于写了一半的状态；两者已经是同一个文件（如未清理的上传文件仍是缓存的硬链接）时不做任何操作
+func (c *Client) LinkFile(src, dst string) error {
+	// 临时文件名带上远程 shell 的进程号，多个传输同时保存同一文件时互不影响
+	command := fmt.Sprintf(`[ %[2]s -ef %[3]s ] && exit 0; tmp=%[3]s.$$.tmp; mkdir -p %[1]s && { ln -f %[2]s "$tmp" 2>/dev/null || cp -f %[2]s "$tmp"; } && mv -f "$tmp" %[3]s || { rm -f "$tmp"; exit 1; }`,
+		shellQuote(path.Dir(dst)), shellQuote(src), shellQuote(dst))
+	if output, err := c.ExecuteCommand(command); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// RemoteFileSize 获取远程文件大小，文件不存在时返回错误
+func (c *Client) RemoteFileSize(remotePath string) (int64, error) {
+	return c.remoteFileSize(remotePath)
+}
+
+// fileDigest 计算本地文件的 SHA-256（十六进制）和大小
+func fileDigest(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("读取本地文件失败: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
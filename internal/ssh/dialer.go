@@ -0,0 +1,313 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Endpoint SSH连接端点（目标主机或跳板机）
+type Endpoint struct {
+	Host string         // 主机地址
+	Port int            // SSH端口
+	User string         // SSH用户名
+	Auth *Authenticator // SSH认证器
+}
+
+// Address 返回 host:port 形式的地址（IPv6 地址会加上方括号）
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// String 返回 user@host:port 形式的描述
+func (e Endpoint) String() string {
+	return e.User + "@" + e.Address()
+}
+
+// Keepalive SSH连接保活配置
+type Keepalive struct {
+	Interval int // 发送 keepalive 请求的间隔（秒），0 表示不发送
+	CountMax int // 连续多少次无响应后断开连接
+}
+
+// Dialer 建立SSH连接，负责主机密钥校验和跳板链
+// 同一条跳板链上的每个跳板机连接在整个运行期间只建立一次，由所有主机共享
+type Dialer struct {
+	hostKeys  *HostKeyVerifier
+	timeout   time.Duration
+	keepalive Keepalive
+
+	mu    sync.Mutex
+	jumps map[string]*jumpConn // 跳板链 -> 跳板机连接
+
+}
+
+// jumpConn 缓存的跳板机连接
+type jumpConn struct {
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewDialer 创建SSH连接器
+func NewDialer(hostKeys *HostKeyVerifier, timeout int, keepalive Keepalive) *Dialer {
+	return &Dialer{
+		hostKeys:  hostKeys,
+		timeout:   time.Duration(timeout) * time.Second,
+		keepalive: keepalive,
+		jumps:     make(map[string]*jumpConn),
+	}
+}
+
+// Dial 连接目标主机，jumps 非空时依次经由跳板机建立隧道
+// out 为交互认证提示的输出位置（如进度条），为 nil 时直接写终端；
+// 连接因 keepalive 无响应被断开时将 lost 置为 true，标记随调用方的连接一起释放
+func (d *Dialer) Dial(target Endpoint, jumps []Endpoint, out io.Writer, lost *atomic.Bool) (*ssh.Client, error) {
+	var via *ssh.Client
+	for i := range jumps {
+		client, err := d.jumpClient(jumps[:i+1], via, out)
+		if err != nil {
+			return nil, err
+		}
+		via = client
+	}
+
+	client, err := d.dial(via, target, out)
+	if err != nil {
+		if len(jumps) > 0 {
+			return nil, fmt.Errorf("经跳板机 %s 连接SSH服务器失败 [%s]: %w", describeChain(jumps), target.Address(), err)
+		}
+		return nil, fmt.Errorf("连接SSH服务器失败 [%s]: %w", target.Address(), err)
+	}
+	d.startKeepalive(client, lost)
+	return client, nil
+}
+
+// jumpClient 获取跳板链最后一跳的连接，已有可用连接时直接复用
+func (d *Dialer) jumpClient(chain []Endpoint, via *ssh.Client, out io.Writer) (*ssh.Client, error) {
+	key := describeChain(chain)
+
+	d.mu.Lock()
+	jc, ok := d.jumps[key]
+	if !ok {
+		jc = &jumpConn{}
+		d.jumps[key] = jc
+	}
+	d.mu.Unlock()
+
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.client != nil {
+		if isAlive(jc.client) {
+			return jc.client, nil
+		}
+		jc.client.Close()
+		jc.client = nil
+	}
+
+	hop := chain[len(chain)-1]
+	client, err := d.dial(via, hop, out)
+	if err != nil {
+		return nil, fmt.Errorf("连接跳板机失败 [%s]: %w", hop.Address(), err)
+	}
+	d.startKeepalive(client, nil)
+	jc.client = client
+	return client, nil
+}
+
+// dial 建立单个SSH连接，via 为空时直接通过 TCP 连接
+func (d *Dialer) dial(via *ssh.Client, ep Endpoint, out io.Writer) (*ssh.Client, error) {
+	config, err := d.clientConfig(ep, out)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := ep.Address()
+	if via == nil {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 隧道连接不支持读写超时，握手超时时直接关闭连接
+	// 超时只覆盖密钥交换和主机密钥校验：认证阶段可能在等待其他主机的交互提示（OTP），
+	// 与直接连接一样不限制时间，避免排队等待终端输入的主机被判定为握手超时
+	authStarted := make(chan struct{})
+	var authOnce sync.Once
+	verifyHostKey := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verifyHostKey(hostname, remote, key); err != nil {
+			return err
+		}
+		authOnce.Do(func() { close(authStarted) })
+		return nil
+	}
+
+	type result struct {
+		client *ssh.Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{client: ssh.NewClient(c, chans, reqs)}
+	}()
+
+	var timeout <-chan time.Time
+	if d.timeout > 0 {
+		timer := time.NewTimer(d.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case res := <-done:
+			if res.err != nil {
+				conn.Close()
+				return nil, res.err
+			}
+			return res.client, nil
+		case <-authStarted:
+			authStarted = nil
+			timeout = nil
+		case <-timeout:
+			conn.Close()
+			return nil, fmt.Errorf("SSH握手超时")
+		}
+	}
+}
+
+// clientConfig 构建连接端点的SSH配置
+func (d *Dialer) clientConfig(ep Endpoint, out io.Writer) (*ssh.ClientConfig, error) {
+	if d.hostKeys == nil {
+		return nil, fmt.Errorf("未配置主机密钥校验器 [%s]", ep.Address())
+	}
+	if ep.Auth == nil {
+		return nil, fmt.Errorf("未配置SSH认证方式 [%s]", ep.Address())
+	}
+
+	// 添加认证方式（按配置顺序：agent → 私钥 → 密码 → 键盘交互）
+	authMethods, err := ep.Auth.AuthMethods(ep.Address(), out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:              ep.User,
+		Auth:              authMethods,
+		HostKeyCallback:   d.hostKeys.Callback(),
+		HostKeyAlgorithms: d.hostKeys.HostKeyAlgorithms(ep.Address()),
+		Timeout:           d.timeout,
+	}, nil
+}
+
+// Close 关闭所有跳板机连接
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, jc := range d.jumps {
+		jc.mu.Lock()
+		if jc.client != nil {
+			jc.client.Close()
+			jc.client = nil
+		}
+		jc.mu.Unlock()
+		delete(d.jumps, key)
+	}
+	return nil
+}
+
+// startKeepalive 定期发送 keepalive 请求，连续 CountMax 次无响应时关闭连接并将 lost 置为 true
+// 关闭连接会让阻塞中的读写立即返回错误，避免传输在防火墙丢弃连接后永久挂起
+func (d *Dialer) startKeepalive(client *ssh.Client, lost *atomic.Bool) {
+	if d.keepalive.Interval <= 0 {
+		return
+	}
+	interval := time.Duration(d.keepalive.Interval) * time.Second
+	countMax := d.keepalive.CountMax
+	if countMax <= 0 {
+		countMax = 1
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		missed := 0
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+
+			if sendKeepalive(client, interval) {
+				missed = 0
+				continue
+			}
+			missed++
+			if missed >= countMax {
+				if lost != nil {
+					lost.Store(true)
+				}
+				client.Close()
+				return
+			}
+		}
+	}()
+}
+
+// aliveTimeout 连接可用性检查的超时时间，网络中断时请求可能一直没有响应
+const aliveTimeout = 10 * time.Second
+
+// isAlive 通过 keepalive 请求检查连接是否仍然可用
+func isAlive(client *ssh.Client) bool {
+	return sendKeepalive(client, aliveTimeout)
+}
+
+// sendKeepalive 发送 keepalive 请求并在超时时间内等待响应
+func sendKeepalive(client *ssh.Client, timeout time.Duration) bool {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// describeChain 返回跳板链描述，同时作为跳板连接的缓存键
+func describeChain(chain []Endpoint) string {
+	hops := make([]string, 0, len(chain))
+	for _, hop := range chain {
+		hops = append(hops, hop.String())
+	}
+	return strings.Join(hops, " -> ")
+}
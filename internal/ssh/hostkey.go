@@ -0,0 +1,311 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// 主机密钥校验策略
+const (
+	HostKeyPolicyStrict    = "strict"     // 只信任 known_hosts 或固定指纹中已有的主机密钥
+	HostKeyPolicyAcceptNew = "accept-new" // 首次连接自动信任并写入 dockship 管理的 known_hosts（TOFU）
+	HostKeyPolicyInsecure  = "insecure"   // 不校验主机密钥（不推荐）
+)
+
+// HostKeyVerifier 主机密钥校验器，在整个运行期间由所有主机连接共享
+type HostKeyVerifier struct {
+	policy      string
+	files       []string            // 参与校验的 known_hosts 文件（用户 + dockship 管理）
+	managedFile string              // accept-new 模式下写入新主机密钥的文件
+	pinned      map[string][]string // 主机 -> 固定指纹列表
+
+	mu       sync.Mutex
+	db       ssh.HostKeyCallback
+	accepted map[string]ssh.PublicKey // 本次运行中新信任的主机密钥
+	trusted  map[string]ssh.PublicKey // 本次运行中校验通过的主机密钥
+}
+
+// NewHostKeyVerifier 创建主机密钥校验器
+// userFiles: 用户的 known_hosts 文件（支持哈希条目），不存在的文件会被忽略
+// managedFile: dockship 管理的 known_hosts 文件
+// pinned: 按主机固定的指纹（SHA256:xxx 格式），优先于 known_hosts
+func NewHostKeyVerifier(policy string, userFiles []string, managedFile string, pinned map[string][]string) (*HostKeyVerifier, error) {
+	switch policy {
+	case HostKeyPolicyStrict, HostKeyPolicyAcceptNew, HostKeyPolicyInsecure:
+	default:
+		return nil, fmt.Errorf("不支持的主机密钥校验策略: %s", policy)
+	}
+
+	v := &HostKeyVerifier{
+		policy:      policy,
+		managedFile: managedFile,
+		pinned:      pinned,
+		accepted:    make(map[string]ssh.PublicKey),
+		trusted:     make(map[string]ssh.PublicKey),
+	}
+
+	for _, file := range append(append([]string{}, userFiles...), managedFile) {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		v.files = append(v.files, file)
+	}
+
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+
+	if policy == HostKeyPolicyInsecure {
+		fmt.Println("⚠️  主机密钥校验已关闭（host_key_policy: insecure），连接可能遭受中间人攻击")
+	}
+	return v, nil
+}
+
+// reload 重新读取 known_hosts 文件
+func (v *HostKeyVerifier) reload() error {
+	if len(v.files) == 0 {
+		v.db = nil
+		return nil
+	}
+	db, err := knownhosts.New(v.files...)
+	if err != nil {
+		return fmt.Errorf("读取 known_hosts 失败: %w", err)
+	}
+	v.db = db
+	return nil
+}
+
+// Callback 返回用于 ssh.ClientConfig 的主机密钥校验回调
+func (v *HostKeyVerifier) Callback() ssh.HostKeyCallback {
+	return v.check
+}
+
+// HostKeyAlgorithms 返回 known_hosts 中该地址已记录的密钥算法
+// 用于在握手时优先协商已知类型的密钥，避免服务端返回其他类型的密钥导致误报不匹配
+func (v *HostKeyVerifier) HostKeyAlgorithms(address string) []string {
+	if v.policy == HostKeyPolicyInsecure {
+		return nil
+	}
+
+	var algos []string
+	seen := make(map[string]bool)
+	add := func(key ssh.PublicKey) {
+		for _, algo := range algorithmsForKey(key) {
+			if !seen[algo] {
+				seen[algo] = true
+				algos = append(algos, algo)
+			}
+		}
+	}
+
+	// 固定指纹无法得知密钥类型，此时交给服务端决定
+	if len(v.pinnedFor(address)) > 0 {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.accepted[knownhosts.Normalize(address)]; ok {
+		add(key)
+	}
+	if v.db != nil {
+		// 用一个不可能匹配的探测密钥查询已记录的密钥列表
+		var keyErr *knownhosts.KeyError
+		if err := v.db(address, &net.TCPAddr{IP: net.IPv4zero}, probeKey); errors.As(err, &keyErr) {
+			for _, known := range keyErr.Want {
+				add(known.Key)
+			}
+		}
+	}
+	return algos
+}
+
+// ForwardOptions 返回其他主机通过 ssh 连接 address 时使用的 StrictHostKeyChecking 取值，
+// 以及写入临时 known_hosts 文件的条目（为 nil 时使用该主机上 ssh 自己的 known_hosts）
+// strict 策略或固定了指纹的主机只信任本机已校验通过的密钥，与本机连接时的校验保持一致
+func (v *HostKeyVerifier) ForwardOptions(address string) (string, []string) {
+	if len(v.pinnedFor(address)) == 0 {
+		switch v.policy {
+		case HostKeyPolicyInsecure:
+			// 不写入转发主机的 known_hosts
+			return "no", []string{}
+		case HostKeyPolicyAcceptNew:
+			return "accept-new", nil
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	lines := []string{}
+	if key, ok := v.trusted[knownhosts.Normalize(address)]; ok {
+		lines = append(lines, knownhosts.Line([]string{knownhosts.Normalize(address)}, key))
+	}
+	return "yes", lines
+}
+
+// check 校验主机密钥，通过时记录下来供 ForwardOptions 使用
+func (v *HostKeyVerifier) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := v.verify(hostname, remote, key)
+	if err == nil {
+		v.mu.Lock()
+		v.trusted[knownhosts.Normalize(hostname)] = key
+		v.mu.Unlock()
+	}
+	return err
+}
+
+// verify 校验主机密钥
+func (v *HostKeyVerifier) verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	// 1. 固定指纹优先
+	if pinned := v.pinnedFor(hostname); len(pinned) > 0 {
+		for _, want := range pinned {
+			if fingerprintMatches(want, key) {
+				return nil
+			}
+		}
+		return fmt.Errorf("主机密钥不匹配 [%s]: 配置固定的指纹为 %s，实际为 %s（可能遭受中间人攻击）",
+			hostname, strings.Join(pinned, ", "), fingerprint)
+	}
+
+	if v.policy == HostKeyPolicyInsecure {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	normalized := knownhosts.Normalize(hostname)
+	if known, ok := v.accepted[normalized]; ok {
+		if string(known.Marshal()) == string(key.Marshal()) {
+			return nil
+		}
+		return mismatchError(hostname, []string{ssh.FingerprintSHA256(known)}, fingerprint)
+	}
+
+	// 2. 查询 known_hosts
+	var err error
+	if v.db != nil {
+		err = v.db(hostname, remote, key)
+	} else {
+		err = &knownhosts.KeyError{}
+	}
+	if err == nil {
+		return nil
+	}
+
+	var revoked *knownhosts.RevokedError
+	if errors.As(err, &revoked) {
+		return fmt.Errorf("主机密钥已被吊销 [%s]: %s", hostname, fingerprint)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return fmt.Errorf("校验主机密钥失败 [%s]: %w", hostname, err)
+	}
+
+	if len(keyErr.Want) > 0 {
+		wants := make([]string, 0, len(keyErr.Want))
+		for _, known := range keyErr.Want {
+			wants = append(wants, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(known.Key), known.Filename, known.Line))
+		}
+		return mismatchError(hostname, wants, fingerprint)
+	}
+
+	// 3. 未知主机
+	if v.policy == HostKeyPolicyStrict {
+		return fmt.Errorf("未知的主机密钥 [%s]: %s %s，请先将其加入 known_hosts 或在 ssh.host_fingerprints 中固定指纹",
+			hostname, key.Type(), fingerprint)
+	}
+
+	if err := v.appendManaged(normalized, key); err != nil {
+		return err
+	}
+	v.accepted[normalized] = key
+	fmt.Printf("🔑 [%s] 首次连接，已信任主机密钥 %s %s（写入 %s）\n", hostname, key.Type(), fingerprint, v.managedFile)
+	return nil
+}
+
+// appendManaged 将新主机密钥写入 dockship 管理的 known_hosts
+func (v *HostKeyVerifier) appendManaged(address string, key ssh.PublicKey) error {
+	if v.managedFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(v.managedFile), 0700); err != nil {
+		return fmt.Errorf("创建 known_hosts 目录失败: %w", err)
+	}
+	f, err := os.OpenFile(v.managedFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("打开 known_hosts 文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{address}, key)); err != nil {
+		return fmt.Errorf("写入 known_hosts 文件失败: %w", err)
+	}
+	return nil
+}
+
+// pinnedFor 查找主机对应的固定指纹，支持 host、host:port 和 [host]:port 三种写法
+func (v *HostKeyVerifier) pinnedFor(hostname string) []string {
+	if len(v.pinned) == 0 {
+		return nil
+	}
+	if fps, ok := v.pinned[hostname]; ok {
+		return fps
+	}
+	if fps, ok := v.pinned[knownhosts.Normalize(hostname)]; ok {
+		return fps
+	}
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		return v.pinned[host]
+	}
+	return nil
+}
+
+// fingerprintMatches 比较指纹，支持 SHA256:xxx 和旧式 MD5 十六进制格式
+func fingerprintMatches(want string, key ssh.PublicKey) bool {
+	want = strings.TrimSpace(want)
+	if strings.HasPrefix(want, "SHA256:") {
+		return want == ssh.FingerprintSHA256(key)
+	}
+	return strings.EqualFold(strings.TrimPrefix(want, "MD5:"), ssh.FingerprintLegacyMD5(key))
+}
+
+// mismatchError 构造主机密钥不匹配错误，同时展示已记录和实际的指纹
+func mismatchError(hostname string, known []string, actual string) error {
+	return fmt.Errorf("主机密钥不匹配 [%s]，可能遭受中间人攻击！\n  已记录指纹: %s\n  实际指纹:   %s\n  如果主机确实重装或更换了密钥，请从 known_hosts 中删除旧条目后重试",
+		hostname, strings.Join(known, "\n               "), actual)
+}
+
+// algorithmsForKey 返回可用于协商该密钥的主机密钥算法
+func algorithmsForKey(key ssh.PublicKey) []string {
+	switch key.Type() {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	case ssh.CertAlgoRSAv01:
+		return []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}
+	default:
+		return []string{key.Type()}
+	}
+}
+
+// probeKey 用于查询 known_hosts 的探测密钥
+var probeKey = func() ssh.PublicKey {
+	key, _ := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	return key
+}()
@@ -0,0 +1,57 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keyboardInteractive 返回 keyboard-interactive 认证的应答函数
+// 密码提示自动使用配置的密码应答（仅第一次），其他提示（如 OTP 验证码）转发到终端输入
+func (a *Authenticator) keyboardInteractive(host string, out io.Writer) ssh.KeyboardInteractiveChallenge {
+	passwordUsed := false
+
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		header := challengeHeader(name, instruction)
+		for i, question := range questions {
+			if isPasswordPrompt(question) && a.cfg.Password != "" && !passwordUsed {
+				answers[i] = a.cfg.Password
+				passwordUsed = true
+				continue
+			}
+
+			prompt := fmt.Sprintf("🔐 [%s] %s", host, strings.TrimSpace(question))
+			if header != "" {
+				// 服务端的说明只在第一个提示前显示一次
+				prompt = fmt.Sprintf("🔐 [%s] %s\n%s", host, header, prompt)
+				header = ""
+			}
+			answer, err := readSecret(out, prompt+" ")
+			if err != nil {
+				return nil, fmt.Errorf("主机 %s 要求交互验证（%s）: %w", host, strings.TrimSpace(question), err)
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
+
+// isPasswordPrompt 判断是否为密码提示（PAM 通常为 "Password: "）
+func isPasswordPrompt(question string) bool {
+	q := strings.ToLower(question)
+	return strings.Contains(q, "password") || strings.Contains(q, "密码")
+}
+
+// challengeHeader 合并服务端返回的名称和说明
+func challengeHeader(name, instruction string) string {
+	var parts []string
+	for _, s := range []string{name, instruction} {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
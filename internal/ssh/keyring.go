@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// maxPassphraseAttempts 交互输入口令的最大尝试次数
+const maxPassphraseAttempts = 3
+
+// Keyring 所有认证器共享的凭据：每个私钥文件只解析（解密）一次，ssh-agent 只连接一次
+// 不同主机和跳板机的认证器使用相同的私钥时，口令在整个运行期间只需提供一次
+type Keyring struct {
+	passphraseEnv  string // 私钥口令所在的环境变量名
+	passphraseFile string // 私钥口令文件路径
+
+	mu         sync.Mutex
+	signers    map[string]ssh.Signer // 私钥文件 -> 签名器
+	passphrase []byte                // 已验证的私钥口令，用于尝试解密其余私钥
+
+	agentOnce sync.Once
+	agentErr  error
+	agentConn net.Conn
+	agent     agent.ExtendedAgent
+}
+
+// NewKeyring 创建共享凭据，passphraseEnv 和 passphraseFile 为私钥口令的来源（可为空）
+func NewKeyring(passphraseEnv, passphraseFile string) *Keyring {
+	return &Keyring{
+		passphraseEnv:  passphraseEnv,
+		passphraseFile: passphraseFile,
+		signers:        make(map[string]ssh.Signer),
+	}
+}
+
+// Signer 返回私钥文件的签名器，首次调用时读取并解析，加密的私钥会获取口令后解密
+func (k *Keyring) Signer(keyFile string) (ssh.Signer, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if signer, ok := k.signers[keyFile]; ok {
+		return signer, nil
+	}
+	signer, err := k.loadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	k.signers[keyFile] = signer
+	return signer, nil
+}
+
+// Agent 返回 SSH_AUTH_SOCK 指向的 ssh-agent，首次调用时连接，不可用时只提示一次
+func (k *Keyring) Agent() (agent.ExtendedAgent, error) {
+	k.agentOnce.Do(func() {
+		k.agentErr = k.connectAgent()
+		if k.agentErr != nil {
+			fmt.Printf("⚠️  ssh-agent 不可用，跳过: %v\n", k.agentErr)
+		}
+	})
+	return k.agent, k.agentErr
+}
+
+// Close 关闭 ssh-agent 连接
+func (k *Keyring) Close() error {
+	if k.agentConn != nil {
+		return k.agentConn.Close()
+	}
+	return nil
+}
+
+// connectAgent 连接 SSH_AUTH_SOCK 指向的 ssh-agent
+func (k *Keyring) connectAgent() error {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return fmt.Errorf("未设置 SSH_AUTH_SOCK")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return fmt.Errorf("连接 ssh-agent 失败: %w", err)
+	}
+	k.agentConn = conn
+	k.agent = agent.NewClient(conn)
+	return nil
+}
+
+// loadKeyFile 读取并解析私钥文件，加密的私钥会获取口令后解密
+func (k *Keyring) loadKeyFile(keyFile string) (ssh.Signer, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取SSH密钥文件失败: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer, nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, fmt.Errorf("解析SSH密钥失败 [%s]: %w", keyFile, err)
+	}
+
+	// 1. 先尝试已验证过的口令（多个私钥常使用相同口令）
+	if k.passphrase != nil {
+		if signer, err := ssh.ParsePrivateKeyWithPassphrase(key, k.passphrase); err == nil {
+			return signer, nil
+		}
+	}
+
+	// 2. 环境变量或口令文件
+	if passphrase, source, err := k.configuredPassphrase(); err != nil {
+		return nil, err
+	} else if passphrase != nil {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("使用%s中的口令解密SSH密钥失败 [%s]: %w", source, keyFile, err)
+		}
+		k.passphrase = passphrase
+		return signer, nil
+	}
+
+	// 3. 在终端交互输入
+	for attempt := 1; attempt <= maxPassphraseAttempts; attempt++ {
+		input, err := readSecret(nil, fmt.Sprintf("🔐 请输入私钥 %s 的口令: ", keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("SSH密钥 %s 已加密，需要口令（可通过 ssh.passphrase_env 或 ssh.passphrase_file 提供）: %w", keyFile, err)
+		}
+		passphrase := []byte(input)
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		if err == nil {
+			k.passphrase = passphrase
+			return signer, nil
+		}
+		if !errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("解密SSH密钥失败 [%s]: %w", keyFile, err)
+		}
+		fmt.Println("❌ 口令错误")
+	}
+	return nil, fmt.Errorf("解密SSH密钥失败 [%s]: 口令错误次数过多", keyFile)
+}
+
+// configuredPassphrase 从环境变量或口令文件读取私钥口令，未配置时返回 nil
+func (k *Keyring) configuredPassphrase() ([]byte, string, error) {
+	if k.passphraseEnv != "" {
+		if value, ok := os.LookupEnv(k.passphraseEnv); ok {
+			return []byte(value), fmt.Sprintf("环境变量 %s ", k.passphraseEnv), nil
+		}
+	}
+	if k.passphraseFile != "" {
+		data, err := os.ReadFile(k.passphraseFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("读取私钥口令文件失败: %w", err)
+		}
+		return bytes.TrimRight(data, "\r\n"), fmt.Sprintf("口令文件 %s ", k.passphraseFile), nil
+	}
+	return nil, "", nil
+}
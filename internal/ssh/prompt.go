@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// promptMu 串行化终端交互，避免多个主机的提示互相穿插
+var promptMu sync.Mutex
+
+// readSecret 在终端上提示并读取一行不回显的输入
+// out 为提示的输出位置，显示进度条期间传入 *mpb.Progress，提示会打印在进度条上方而不会被刷新覆盖；为 nil 时直接写终端
+func readSecret(out io.Writer, prompt string) (string, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	tty, closeTTY, err := openTTY()
+	if err != nil {
+		return "", err
+	}
+	defer closeTTY()
+
+	// 进度条运行期间由进度条输出提示；输入不回显，不会与进度条的刷新互相干扰
+	if out == nil || writePrompt(out, prompt) != nil {
+		fmt.Fprint(tty, prompt)
+		defer fmt.Fprintln(tty)
+	}
+	secret, err := term.ReadPassword(int(tty.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("读取终端输入失败: %w", err)
+	}
+	return string(secret), nil
+}
+
+// writePrompt 输出一行提示
+func writePrompt(w io.Writer, prompt string) error {
+	_, err := fmt.Fprintln(w, strings.TrimRight(prompt, " "))
+	return err
+}
+
+// openTTY 打开交互终端，优先使用 /dev/tty，其次使用标准输入
+func openTTY() (*os.File, func(), error) {
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		return tty, func() { tty.Close() }, nil
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("当前不是交互终端，无法输入")
+}
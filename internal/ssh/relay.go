@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/crypto/ssh"
+)
+
+// relayPollInterval 中继转发时查询接收方文件大小的间隔
+const relayPollInterval = 500 * time.Millisecond
+
+// Peer 中继转发的接收方，由转发主机上的 ssh 命令连接
+type Peer struct {
+	Address         string   // 接收方地址
+	Port            int      // SSH端口
+	User            string   // SSH用户名
+	KeyFile         string   // 转发主机上的私钥路径，为空时使用转发主机上 ssh 的默认配置
+	Options         []string // 额外的 ssh -o 选项
+	Timeout         int      // 连接超时（秒）
+	HostKeyChecking string   // StrictHostKeyChecking 的取值，为空时为 accept-new
+	KnownHosts      []string // 写入临时 known_hosts 文件的条目，为 nil 时使用转发主机上 ssh 自己的 known_hosts
+}
+
+// command 返回在转发主机上执行的 ssh 命令，将转发主机上的 src 写入接收方的 dst
+// 先写入 dst.part 再改名，接收中断时不会留下看似完整的文件
+// 用户配置的选项放在默认选项之前：ssh 对同一选项只采用第一次出现的值
+func (p Peer) command(src, dst string) string {
+	checking := p.HostKeyChecking
+	if checking == "" {
+		checking = "accept-new"
+	}
+	args := []string{"ssh", "-p", strconv.Itoa(p.Port)}
+	for _, opt := range p.Options {
+		args = append(args, "-o", shellQuote(opt))
+	}
+	args = append(args, "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking="+checking)
+	if p.KnownHosts != nil {
+		args = append(args, "-o", `UserKnownHostsFile="$kh"`, "-o", "GlobalKnownHostsFile=/dev/null")
+	}
+	if p.Timeout > 0 {
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", p.Timeout))
+	}
+	if p.KeyFile != "" {
+		args = append(args, "-i", shellQuote(p.KeyFile))
+	}
+
+	part := dst + ".part"
+	remote := fmt.Sprintf("mkdir -p %s && cat > %s && mv -f %s %s",
+		shellQuote(path.Dir(dst)), shellQuote(part), shellQuote(part), shellQuote(dst))
+	args = append(args, shellQuote(p.User+"@"+p.Address), shellQuote(remote), "<", shellQuote(src))
+	cmd := strings.Join(args, " ")
+	if p.KnownHosts == nil {
+		return cmd
+	}
+
+	// 只信任指定的主机密钥：写入临时 known_hosts 文件，结束后删除
+	write := `: > "$kh"`
+	if len(p.KnownHosts) > 0 {
+		quoted := make([]string, len(p.KnownHosts))
+		for i, line := range p.KnownHosts {
+			quoted[i] = shellQuote(line)
+		}
+		write = `printf '%s\n' ` + strings.Join(quoted, " ") + ` > "$kh"`
+	}
+	return fmt.Sprintf(`kh=$(mktemp) && { %s && %s; rc=$?; rm -f "$kh"; exit $rc; }`, write, cmd)
+}
+
+// PushFile 在当前主机上执行 ssh，将远程文件 src（size 字节）转发到 peer 的 dst
+// 数据只经过两台主机之间的链路；watch 为本机到接收方的连接，用于查询已接收的大小以显示进度和检测停滞
+func (c *Client) PushFile(src string, size int64, peer Peer, dst string, watch *Client, progress *mpb.Progress) error {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+	if err := session.Start(peer.command(src, dst)); err != nil {
+		return fmt.Errorf("启动转发失败: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	bar := progress.AddBar(size,
+		mpb.BarRemoveOnComplete(),
+		mpb.PrependDecorators(
+			decor.Name(fmt.Sprintf("🔁 [%s ← %s]", watch.host, c.host), decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(
+			decor.CountersKibiByte("%.1f / %.1f"),
+			decor.NewPercentage("%d"),
+			decor.AverageSpeed(decor.SizeB1024(0), " %.1f/s"),
+		),
+	)
+
+	// 停滞检测：接收方文件大小超时没有变化时终止转发
+	var watchdog *stallWatchdog
+	if c.stallTimeout > 0 {
+		watchdog = newStallWatchdog(c.stallTimeout, func() {
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		}, func() { c.sshClient.Close() })
+		defer watchdog.stop()
+	}
+
+	ticker := time.NewTicker(relayPollInterval)
+	defer ticker.Stop()
+	var received int64
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				bar.Abort(false)
+				watch.RemoveRemoteFile(dst + ".part")
+				if watchdog != nil && watchdog.isStalled() {
+					return fmt.Errorf("转发停滞: %s 内没有任何进展（已接收 %d / %d 字节）: %w", c.stallTimeout, received, size, ErrStalled)
+				}
+				return fmt.Errorf("从 %s 转发失败: %w: %s", c.host, err, strings.TrimSpace(output.String()))
+			}
+			bar.SetCurrent(size)
+			bar.EnableTriggerComplete()
+			return nil
+		case <-ticker.C:
+			n, err := watch.remoteFileSize(dst + ".part")
+			if err == nil && n > received {
+				received = n
+				bar.SetCurrent(n)
+				if watchdog != nil {
+					watchdog.touch()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// resumeCheckSize 续传前校验的重叠部分长度（远程已有数据的末尾）
+const resumeCheckSize = 1 << 20
+
+// ResumeOffset 检查远程已有的部分文件，返回可以续传的位置，无法续传时返回 0
+// 远程文件比本地小时，比较两端重叠部分末尾 resumeCheckSize 字节的 SHA-256，一致才从远程文件末尾续传；
+// scp 上传不能续写已有文件，总是从头上传
+func (c *Client) ResumeOffset(local io.ReaderAt, size int64, remotePath string) (int64, error) {
+	if c.UploadMethod() == UploadSCP {
+		return 0, nil
+	}
+
+	remoteSize, err := c.remoteFileSize(remotePath)
+	if err != nil || remoteSize <= 0 || remoteSize >= size {
+		// 远程文件不存在、为空或大小不小于本地文件，不能续传
+		return 0, nil
+	}
+
+	length := min(remoteSize, resumeCheckSize)
+	offset := remoteSize - length
+
+	localHash := sha256.New()
+	if _, err := io.Copy(localHash, io.NewSectionReader(local, offset, length)); err != nil {
+		return 0, fmt.Errorf("读取本地文件失败: %w", err)
+	}
+	remoteHash, err := c.remoteHash(remotePath, offset, length)
+	if err != nil {
+		return 0, err
+	}
+	if hex.EncodeToString(localHash.Sum(nil)) != remoteHash {
+		return 0, nil
+	}
+	return remoteSize, nil
+}
+
+// remoteFileSize 获取远程文件大小，SFTP 不可用时通过 wc -c 获取
+func (c *Client) remoteFileSize(remotePath string) (int64, error) {
+	if c.sftpClient != nil {
+		info, err := c.sftpClient.Stat(remotePath)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return execFileSize(c.sshClient, remotePath)
+}
+
+// remoteHash 计算远程文件从 offset 开始 length 字节的 SHA-256（十六进制）
+// SFTP 可用时读回数据在本地计算，否则在远程执行 sha256sum
+func (c *Client) remoteHash(remotePath string, offset, length int64) (string, error) {
+	if c.sftpClient != nil {
+		return c.sftpHash(remotePath, offset, length)
+	}
+
+	// tail -c +N 从第 N 个字节（从 1 开始）输出
+	command := fmt.Sprintf("tail -c +%d %s | head -c %d | sha256sum", offset+1, shellQuote(remotePath), length)
+	output, err := c.ExecuteCommand(command)
+	if err != nil {
+		return "", fmt.Errorf("计算远程文件校验和失败: %w: %s", err, strings.TrimSpace(output))
+	}
+	return parseSHA256(output)
+}
+
+// resumedSpeed 续传时的平均速率，只计算本次传输的字节数
+func resumedSpeed(offset int64) decor.Decorator {
+	start := time.Now()
+	return decor.Any(func(s decor.Statistics) string {
+		elapsed := time.Since(start).Seconds()
+		if elapsed <= 0 {
+			return ""
+		}
+		return fmt.Sprintf(" %.1f/s", decor.SizeB1024(float64(s.Current-offset)/elapsed))
+	})
+}
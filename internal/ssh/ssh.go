@@ -1,11 +1,14 @@
 package ssh
 
 import (
+	"dockship/internal/bandwidth"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/sftp"
@@ -15,76 +18,105 @@ import (
 )
 
 // Client SSH客户端
+// 一个客户端对应一条SSH连接和一个SFTP会话，可以被多个镜像的传输并发使用
 type Client struct {
 	host       string
-	port       int
-	user       string
-	password   string
-	keyFile    string
-	timeout    time.Duration
+	endpoint   Endpoint   // 目标主机
+	jumpHosts  []Endpoint // 跳板链
+	dialer     *Dialer
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client
-	progress   *mpb.Progress // 多进度条容器
+	become     Become   // docker 命令和 hooks 的提权配置
+	uploader   uploader // 上传方式
+
+	stallTimeout time.Duration      // 上传无进展超过该时间时中断，0 表示不检测
+	bandwidth    *bandwidth.Limiter // 上传限速，为 nil 时不限速
+
+	lost atomic.Bool // 连接因 keepalive 无响应被断开
+}
+
+// ClientOptions SSH客户端连接参数
+type ClientOptions struct {
+	Name      string     // 主机名称（用于日志输出），为空时使用主机地址
+	Endpoint             // 目标主机
+	JumpHosts []Endpoint // 跳板链，按顺序逐跳连接
+	Dialer    *Dialer    // SSH连接器（主机密钥校验、跳板连接复用）
+	Become    Become     // 远程 docker 命令（以及 hooks）的提权配置
+
+	UploadMethod string // 上传方式: auto/sftp/scp/cat，为空时为 auto
+
+	StallTimeout time.Duration      // 上传停滞超时，0 表示不检测
+	Bandwidth    *bandwidth.Limiter // 上传限速（按主机名称匹配单独限速），为 nil 时不限速
 }
 
 // NewClient 创建SSH客户端
-func NewClient(host string, port int, user, password, keyFile string, timeout int, progress *mpb.Progress) *Client {
+func NewClient(opts ClientOptions) *Client {
+	host := opts.Name
+	if host == "" {
+		host = opts.Host
+	}
+	method := opts.UploadMethod
+	if method == "" {
+		method = UploadAuto
+	}
 	return &Client{
-		host:     host,
-		port:     port,
-		user:     user,
-		password: password,
-		keyFile:  keyFile,
-		timeout:  time.Duration(timeout) * time.Second,
-		progress: progress,
+		host:      host,
+		endpoint:  opts.Endpoint,
+		jumpHosts: opts.JumpHosts,
+		dialer:    opts.Dialer,
+		become:    opts.Become,
+		uploader:  uploader{method: method},
+
+		stallTimeout: opts.StallTimeout,
+		bandwidth:    opts.Bandwidth,
 	}
 }
 
 // Connect 连接到SSH服务器
-func (c *Client) Connect() error {
-	// 构建SSH配置
-	config := &ssh.ClientConfig{
-		User:            c.user,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 生产环境应该验证主机密钥
-		Timeout:         c.timeout,
-	}
-
-	// 添加认证方式
-	if c.keyFile != "" {
-		// 使用密钥认证
-		key, err := os.ReadFile(c.keyFile)
-		if err != nil {
-			return fmt.Errorf("读取SSH密钥文件失败: %w", err)
-		}
-
-		signer, err := ssh.ParsePrivateKey(key)
-		if err != nil {
-			return fmt.Errorf("解析SSH密钥失败: %w", err)
-		}
+// progress 为当前显示的进度条，交互认证的提示会打印在进度条上方，避免被进度条刷新覆盖
+func (c *Client) Connect(progress *mpb.Progress) error {
+	if c.dialer == nil {
+		return fmt.Errorf("未配置SSH连接器 [%s]", c.endpoint.Address())
+	}
 
-		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
-	} else if c.password != "" {
-		// 使用密码认证
-		config.Auth = []ssh.AuthMethod{ssh.Password(c.password)}
+	var out io.Writer
+	if progress != nil {
+		out = progress
 	}
 
-	// 连接SSH服务器
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
-	sshClient, err := ssh.Dial("tcp", addr, config)
+	// 连接SSH服务器（经由跳板链时复用已建立的跳板连接）
+	sshClient, err := c.dialer.Dial(c.endpoint, c.jumpHosts, out, &c.lost)
 	if err != nil {
-		return fmt.Errorf("连接SSH服务器失败 [%s]: %w", addr, err)
+		return err
 	}
 
 	c.sshClient = sshClient
 
-	// 创建SFTP客户端
+	// 创建SFTP客户端（只使用 scp/cat 上传时不需要）
+	method := c.uploader.method
+	if method != UploadSFTP && method != UploadAuto {
+		return nil
+	}
 	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
-		sshClient.Close()
-		return fmt.Errorf("创建SFTP客户端失败: %w", err)
+		if method == UploadSFTP {
+			sshClient.Close()
+			return fmt.Errorf("创建SFTP客户端失败: %w", err)
+		}
+		// 部分加固过的主机禁用了 sftp-server 子系统，auto 模式下改用 scp/cat 上传
+		notice := fmt.Sprintf("  ⚠️  [%s] SFTP 子系统不可用（%v），改用 scp/cat 上传\n", c.host, err)
+		if out != nil {
+			fmt.Fprint(out, notice)
+		} else {
+			fmt.Print(notice)
+		}
+		return nil
 	}
 
 	c.sftpClient = sftpClient
+	if method == UploadAuto {
+		c.uploader.method = UploadSFTP
+	}
 	return nil
 }
 
@@ -99,8 +131,13 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// UploadFile 上传文件到远程服务器
-func (c *Client) UploadFile(localPath, remotePath string) error {
+// Alive 检查连接是否仍然可用
+func (c *Client) Alive() bool {
+	return c.sshClient != nil && isAlive(c.sshClient)
+}
+
+// UploadFile 上传文件到远程服务器，并在 progress 中显示上传进度
+func (c *Client) UploadFile(localPath, remotePath string, progress *mpb.Progress) error {
 	// 打开本地文件
 	localFile, err := os.Open(localPath)
 	if err != nil {
@@ -114,46 +151,107 @@ func (c *Client) UploadFile(localPath, remotePath string) error {
 		return fmt.Errorf("获取文件信息失败: %w", err)
 	}
 
+	return c.UploadReader(localFile, fileInfo.Size(), remotePath, progress)
+}
+
+// UploadReader 将 r 中 size 字节的数据上传为远程文件，并在 progress 中显示上传进度
+// r 可以是本地文件，也可以是批量分发时多台主机共享的数据流
+// size 小于 0 表示大小未知（如边读边压缩的数据），此时不能使用 scp 上传，进度条只显示已传输字节数
+func (c *Client) UploadReader(r io.Reader, size int64, remotePath string, progress *mpb.Progress) error {
+	return c.ResumeReader(r, size, 0, remotePath, progress)
+}
+
+// ResumeReader 从远程文件的 offset 处继续上传，r 从本地数据的 offset 处开始读取
+// offset 需要先经 ResumeOffset 确认；为 0 时创建新文件，与 UploadReader 相同
+func (c *Client) ResumeReader(r io.Reader, size, offset int64, remotePath string, progress *mpb.Progress) error {
 	// 确保远程目录存在
-	remoteDir := filepath.Dir(remotePath)
-	if err := c.sftpClient.MkdirAll(remoteDir); err != nil {
+	remoteDir := path.Dir(remotePath)
+	if err := c.mkdirAll(remoteDir); err != nil {
 		return fmt.Errorf("创建远程目录失败: %w", err)
 	}
 
-	// 创建远程文件
-	remoteFile, err := c.sftpClient.Create(remotePath)
+	// 从头上传时先删除已有的文件：远程文件可能与缓存的基准文件是同一个硬链接，
+	// 原地截断会破坏缓存，上传中断时缓存也随之损坏
+	if offset == 0 {
+		c.RemoveRemoteFile(remotePath)
+	}
+
+	// 创建远程文件（sftp/scp/cat），续传时打开已有文件并定位到末尾
+	remoteFile, err := c.openRemote(remotePath, size, offset)
 	if err != nil {
-		return fmt.Errorf("创建远程文件失败: %w", err)
+		return err
 	}
 	defer remoteFile.Close()
 
-	// 创建进度条
-	bar := c.progress.AddBar(fileInfo.Size(),
-		mpb.BarRemoveOnComplete(),
-		mpb.PrependDecorators(
-			decor.Name(fmt.Sprintf("📤 [%s]", c.host), decor.WCSyncWidth),
-		),
-		mpb.AppendDecorators(
-			decor.CountersKibiByte("%.1f / %.1f"),
-			decor.NewPercentage("%d"),
-			decor.AverageSpeed(decor.SizeB1024(0), " %.1f/s"),
-		),
-	)
+	// 创建进度条，续传时从断点处开始
+	var bar *mpb.Bar
+	if size >= 0 {
+		speed := decor.AverageSpeed(decor.SizeB1024(0), " %.1f/s")
+		if offset > 0 {
+			speed = resumedSpeed(offset)
+		}
+		bar = progress.AddBar(size,
+			mpb.BarRemoveOnComplete(),
+			mpb.PrependDecorators(
+				decor.Name(fmt.Sprintf("📤 [%s]", c.host), decor.WCSyncWidth),
+			),
+			mpb.AppendDecorators(
+				decor.CountersKibiByte("%.1f / %.1f"),
+				decor.NewPercentage("%d"),
+				speed,
+			),
+		)
+		bar.SetCurrent(offset)
+	} else {
+		bar = progress.AddBar(0,
+			mpb.BarRemoveOnComplete(),
+			mpb.PrependDecorators(
+				decor.Name(fmt.Sprintf("📤 [%s]", c.host), decor.WCSyncWidth),
+			),
+			mpb.AppendDecorators(
+				decor.CurrentKibiByte("%.1f"),
+				decor.AverageSpeed(decor.SizeB1024(0), " %.1f/s"),
+			),
+		)
+	}
+
+	// 停滞检测：超时没有进展时中止本次写入的会话，让阻塞中的写入返回
+	// 会话中止后仍未返回时才关闭连接，此时 Alive 检查失败，重试时会重新建立连接
+	var watchdog *stallWatchdog
+	if c.stallTimeout > 0 {
+		watchdog = newStallWatchdog(c.stallTimeout, remoteFile.Abort, func() { c.sshClient.Close() })
+		defer watchdog.stop()
+	}
+
+	// 限速：写入前按分得的速率等待，等待期间不算停滞
+	throttle := c.bandwidth.Open(c.host)
+	defer throttle.Close()
 
 	// 使用缓冲区分块传输并更新进度
 	buffer := make([]byte, 32*1024) // 32KB 缓冲区
-	var written int64
+	written := offset
 
 	for {
-		nr, errRead := localFile.Read(buffer)
+		nr, errRead := r.Read(buffer)
 		if nr > 0 {
+			waitThrottle(throttle, watchdog, nr)
 			nw, errWrite := remoteFile.Write(buffer[0:nr])
 			if nw > 0 {
 				written += int64(nw)
 				bar.SetCurrent(written) // 手动更新进度
+				if watchdog != nil {
+					watchdog.touch()
+				}
 			}
 			if errWrite != nil {
 				bar.Abort(false)
+				if watchdog != nil && watchdog.isStalled() {
+					return fmt.Errorf("上传停滞: %s 内没有任何进展（已传输 %d / %d 字节），已中止: %w",
+						c.stallTimeout, written, size, ErrStalled)
+				}
+				if c.lost.Load() {
+					return fmt.Errorf("写入远程文件失败（已传输 %d / %d 字节）: %w", written, size, ErrKeepaliveTimeout)
+				}
 				return fmt.Errorf("写入远程文件失败: %w", errWrite)
 			}
 			if nr != nw {
@@ -166,18 +264,31 @@ func (c *Client) UploadFile(localPath, remotePath string) error {
 		}
 		if errRead != nil {
 			bar.Abort(false)
-			return fmt.Errorf("读取本地文件失败: %w", errRead)
+			return fmt.Errorf("读取上传数据失败: %w", errRead)
 		}
 	}
 
-	if written != fileInfo.Size() {
+	if size >= 0 && written != size {
+		bar.Abort(false)
+		return fmt.Errorf("文件上传不完整: 期望 %d 字节，实际 %d 字节", size, written)
+	}
+
+	// 完成远程写入（scp 等待远程确认，cat 核对远程文件大小）
+	if err := remoteFile.Commit(); err != nil {
 		bar.Abort(false)
-		return fmt.Errorf("文件上传不完整: 期望 %d 字节，实际 %d 字节", fileInfo.Size(), written)
+		if watchdog != nil && watchdog.isStalled() {
+			return fmt.Errorf("上传停滞: %s 内没有任何进展，已中止: %w", c.stallTimeout, ErrStalled)
+		}
+		return fmt.Errorf("完成远程文件写入失败: %w", err)
 	}
 
 	// 标记进度条完成并清除
-	bar.SetCurrent(fileInfo.Size())
-	bar.EnableTriggerComplete()
+	if size >= 0 {
+		bar.SetCurrent(size)
+		bar.EnableTriggerComplete()
+	} else {
+		bar.SetTotal(-1, true)
+	}
 
 	return nil
 }
@@ -201,26 +312,74 @@ func (c *Client) ExecuteCommand(command string) (string, error) {
 }
 
 // LoadDockerImage 在远程主机上加载Docker镜像
-func (c *Client) LoadDockerImage(remoteTarPath string) error {
-	command := fmt.Sprintf("docker load -i %s", remoteTarPath)
-	output, err := c.ExecuteCommand(command)
+// codec 为 tar 文件的压缩算法：docker load 可以直接读取 gzip，zstd 需要先经远程 zstd 解压
+func (c *Client) LoadDockerImage(remoteTarPath, codec string) error {
+	command := fmt.Sprintf("docker load -i %s", shellQuote(remoteTarPath))
+	if codec == "zstd" {
+		command = fmt.Sprintf("zstd -dc %s | docker load", shellQuote(remoteTarPath))
+	}
+	output, err := c.ExecutePrivileged(command)
 	if err != nil {
 		return fmt.Errorf("加载Docker镜像失败: %w\n输出: %s", err, output)
 	}
 	return nil
 }
 
-// RemoveRemoteFile 删除远程文件
+// RemoteImageID 返回远程主机上镜像的 ID，镜像不存在或查询失败时返回空字符串
+func (c *Client) RemoteImageID(image string) string {
+	output, err := c.ExecutePrivileged("docker image inspect -f '{{.Id}}' " + shellQuote(image))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// RemoteImageLayers 返回远程主机上每个镜像的层（RootFS.Layers，即各层的 diffID）
+func (c *Client) RemoteImageLayers() ([][]string, error) {
+	command := "docker image ls -q --no-trunc | sort -u | xargs -r docker image inspect --format '{{json .RootFS.Layers}}'"
+	output, err := c.ExecutePrivileged(command)
+	if err != nil {
+		return nil, fmt.Errorf("获取远程镜像层失败: %w\n输出: %s", err, strings.TrimSpace(output))
+	}
+
+	var images [][]string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		var layers []string
+		if err := json.Unmarshal([]byte(line), &layers); err != nil {
+			continue
+		}
+		images = append(images, layers)
+	}
+	return images, nil
+}
+
+// RemoveRemoteFile 删除远程文件，SFTP 不可用时通过 rm -f 删除
 func (c *Client) RemoveRemoteFile(remotePath string) error {
+	if c.sftpClient == nil {
+		if output, err := c.ExecuteCommand("rm -f " + shellQuote(remotePath)); err != nil {
+			return fmt.Errorf("删除远程文件失败: %w: %s", err, strings.TrimSpace(output))
+		}
+		return nil
+	}
 	if err := c.sftpClient.Remove(remotePath); err != nil {
 		return fmt.Errorf("删除远程文件失败: %w", err)
 	}
 	return nil
 }
 
+// HasCommand 检查远程主机上是否存在指定命令
+func (c *Client) HasCommand(name string) bool {
+	_, err := c.ExecuteCommand("command -v " + shellQuote(name))
+	return err == nil
+}
+
 // CheckDockerAvailable 检查远程主机的Docker是否可用
 func (c *Client) CheckDockerAvailable() error {
-	_, err := c.ExecuteCommand("docker version")
+	_, err := c.ExecutePrivileged("docker version")
 	if err != nil {
 		return fmt.Errorf("远程主机Docker不可用: %w", err)
 	}
@@ -249,7 +408,13 @@ func (c *Client) ExecuteHooks(stage string, commands []string, vars map[string]s
 
 		fmt.Printf("    [%s][%d/%d] 执行: %s\n", c.host, i+1, len(commands), cmd)
 
-		output, err := c.ExecuteCommand(cmd)
+		var output string
+		var err error
+		if c.become.Hooks {
+			output, err = c.ExecutePrivileged(cmd)
+		} else {
+			output, err = c.ExecuteCommand(cmd)
+		}
 		if err != nil {
 			hasError = true
 			fmt.Printf("    [%s] ❌ 失败: %v\n", c.host, err)
@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"dockship/internal/bandwidth"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled 传输在停滞超时内没有任何进展
+var ErrStalled = errors.New("传输停滞（stalled）")
+
+// ErrKeepaliveTimeout SSH连接的 keepalive 请求连续无响应，连接已被断开
+var ErrKeepaliveTimeout = errors.New("SSH keepalive 无响应，连接已断开")
+
+// stallEscalateAfter 中止停滞的会话后，写入仍未返回时再等待多久关闭整个连接
+const stallEscalateAfter = 10 * time.Second
+
+// stallWatchdog 监控传输进度，超过 timeout 没有进展时调用 abort 中止本次传输的会话
+// 连接由同一主机的其他传输共享，因此先只中止停滞的会话；写入可能因防火墙丢弃连接而永久阻塞，
+// 此时会话的关闭得不到对端确认，stallEscalateAfter 后仍未结束时调用 escalate 关闭整个连接
+type stallWatchdog struct {
+	timeout  time.Duration
+	abort    func()
+	escalate func()
+
+	last    atomic.Int64 // 最近一次进展的时间（UnixNano）
+	paused  atomic.Bool  // 正在按限速等待，不检测停滞
+	stalled atomic.Bool
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newStallWatchdog 创建并启动停滞监控，escalate 为 nil 时只调用 abort
+func newStallWatchdog(timeout time.Duration, abort, escalate func()) *stallWatchdog {
+	w := &stallWatchdog{
+		timeout:  timeout,
+		abort:    abort,
+		escalate: escalate,
+		done:     make(chan struct{}),
+	}
+	w.touch()
+	go w.run()
+	return w
+}
+
+// touch 记录一次传输进展
+func (w *stallWatchdog) touch() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+// pause 暂停停滞检测，用于按限速等待期间
+func (w *stallWatchdog) pause() {
+	w.paused.Store(true)
+}
+
+// resume 恢复停滞检测，从现在开始重新计时
+func (w *stallWatchdog) resume() {
+	w.touch()
+	w.paused.Store(false)
+}
+
+// isStalled 返回传输是否因停滞被中断
+func (w *stallWatchdog) isStalled() bool {
+	return w.stalled.Load()
+}
+
+// stop 停止监控
+func (w *stallWatchdog) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (w *stallWatchdog) run() {
+	// 检查间隔取超时时间的 1/10，限制在 100ms 到 1s 之间
+	interval := w.timeout / 10
+	interval = max(interval, 100*time.Millisecond)
+	interval = min(interval, time.Second)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		if w.paused.Load() {
+			continue
+		}
+		if time.Since(time.Unix(0, w.last.Load())) >= w.timeout {
+			w.stalled.Store(true)
+			w.abort()
+			if w.escalate == nil {
+				return
+			}
+			select {
+			case <-w.done:
+			case <-time.After(stallEscalateAfter):
+				w.escalate()
+			}
+			return
+		}
+	}
+}
+
+// waitThrottle 写入 n 字节前按限速等待，等待期间暂停停滞检测（watchdog 为 nil 时不检测）
+// 限速很低时单次等待可能超过停滞超时，不能把限速造成的等待当作传输停滞
+func waitThrottle(throttle *bandwidth.Stream, watchdog *stallWatchdog, n int) {
+	if watchdog == nil {
+		throttle.Wait(n)
+		return
+	}
+	watchdog.pause()
+	throttle.Wait(n)
+	watchdog.resume()
+}
@@ -0,0 +1,43 @@
+package ssh
+
+import (
+	"dockship/internal/bandwidth"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 限速很低时单次等待超过停滞超时，等待期间不应判定为停滞
+func TestWaitThrottleNotStalled(t *testing.T) {
+	limiter := bandwidth.New(1000, nil)
+	throttle := limiter.Open("host")
+	defer throttle.Close()
+
+	var aborted atomic.Bool
+	watchdog := newStallWatchdog(100*time.Millisecond, func() { aborted.Store(true) }, nil)
+	defer watchdog.stop()
+
+	// 每次等待约 0.5 秒，远超停滞超时
+	for range 3 {
+		waitThrottle(throttle, watchdog, 500)
+		watchdog.touch()
+	}
+	if watchdog.isStalled() || aborted.Load() {
+		t.Fatal("按限速等待期间被判定为停滞")
+	}
+}
+
+func TestStallWatchdogAborts(t *testing.T) {
+	aborted := make(chan struct{})
+	watchdog := newStallWatchdog(100*time.Millisecond, func() { close(aborted) }, nil)
+	defer watchdog.stop()
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有进展时停滞监控未中止传输")
+	}
+	if !watchdog.isStalled() {
+		t.Error("isStalled() = false, want true")
+	}
+}
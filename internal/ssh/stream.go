@@ -0,0 +1,228 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/crypto/ssh"
+)
+
+// streamReadyMarker 远程命令开始执行的标记
+// 经 sudo 提权时先输出该标记再执行 docker load，收到标记后才开始发送镜像数据，
+// 避免口令提示之前发送的数据被 sudo 当作口令读取
+const streamReadyMarker = "[dockship-stream-ready]"
+
+// streamSession 标准输入接收数据流的远程命令
+type streamSession struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	output  *streamWatcher
+	done    chan error // 远程命令的退出结果
+}
+
+// LoadDockerImageStream 将镜像 tar 流通过SSH会话的标准输入直接导入远程 docker load，两端都不落盘
+// r 读取出错（本地 docker save 失败）时终止远程命令，避免导入不完整的镜像；
+// 远程命令失败时返回错误，由调用方终止本地 docker save
+// estimate 为镜像的估算大小，用于显示进度，为 0 时只显示已传输字节数
+// codec 为数据流的压缩算法：docker load 可以直接读取 gzip，zstd 需要先经远程 zstd 解压
+func (c *Client) LoadDockerImageStream(r io.Reader, estimate int64, codec string, progress *mpb.Progress) error {
+	command := "docker load"
+	if codec == "zstd" {
+		command = "zstd -dc | docker load"
+	}
+	stream, err := c.startStream(command)
+	if err != nil {
+		return err
+	}
+	defer stream.session.Close()
+
+	// 流的总大小未知，进度条不随字节数自动完成，结束时再按实际大小完成
+	bar := progress.AddBar(0,
+		mpb.BarRemoveOnComplete(),
+		mpb.PrependDecorators(
+			decor.Name(fmt.Sprintf("🌊 [%s]", c.host), decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(
+			decor.CountersKibiByte("%.1f / %.1f"),
+			decor.AverageSpeed(decor.SizeB1024(0), " %.1f/s"),
+		),
+	)
+	if estimate > 0 {
+		bar.SetTotal(estimate, false)
+	}
+
+	var watchdog *stallWatchdog
+	if c.stallTimeout > 0 {
+		watchdog = newStallWatchdog(c.stallTimeout, func() {
+			stream.session.Signal(ssh.SIGKILL)
+			stream.session.Close()
+		}, func() { c.sshClient.Close() })
+		defer watchdog.stop()
+	}
+
+	throttle := c.bandwidth.Open(c.host)
+	defer throttle.Close()
+
+	buffer := make([]byte, 32*1024)
+	var written int64
+
+	for {
+		nr, errRead := r.Read(buffer)
+		if nr > 0 {
+			waitThrottle(throttle, watchdog, nr)
+			nw, errWrite := stream.stdin.Write(buffer[:nr])
+			if nw > 0 {
+				written += int64(nw)
+				bar.SetCurrent(written)
+				if watchdog != nil {
+					watchdog.touch()
+				}
+			}
+			if errWrite != nil {
+				bar.Abort(false)
+				if watchdog != nil && watchdog.isStalled() {
+					return fmt.Errorf("流式传输停滞: %s 内没有任何进展（已传输 %d 字节），已中止: %w", c.stallTimeout, written, ErrStalled)
+				}
+				if c.lost.Load() {
+					return fmt.Errorf("流式传输中断（已传输 %d 字节）: %w", written, ErrKeepaliveTimeout)
+				}
+				output, err := stream.abort()
+				return fmt.Errorf("远程 docker load 中断（已传输 %d 字节）: %w\n输出: %s", written, err, output)
+			}
+		}
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			bar.Abort(false)
+			stream.abort()
+			return fmt.Errorf("读取本地镜像流失败，已终止远程 docker load: %w", errRead)
+		}
+	}
+
+	// 数据发送完毕，关闭标准输入让 docker load 结束
+	stream.stdin.Close()
+	if err := <-stream.done; err != nil {
+		bar.Abort(false)
+		if watchdog != nil && watchdog.isStalled() {
+			return fmt.Errorf("流式传输停滞: %s 内没有任何进展，已中止: %w", c.stallTimeout, ErrStalled)
+		}
+		return fmt.Errorf("加载Docker镜像失败: %w\n输出: %s", err, stream.output.String())
+	}
+
+	bar.SetTotal(-1, true)
+	return nil
+}
+
+// startStream 启动从标准输入读取数据的远程命令（按配置提权），返回时远程命令已就绪
+func (c *Client) startStream(command string) (*streamSession, error) {
+	if c.become.Method == BecomeSu {
+		return nil, fmt.Errorf("stream 模式不支持 su 提权（su 需要伪终端，无法传输二进制数据），请改用 sudo 或 file 模式")
+	}
+
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("创建标准输入失败: %w", err)
+	}
+
+	w := &streamWatcher{stdin: stdin, password: c.become.Password, ready: make(chan struct{})}
+	session.Stdout = w
+	session.Stderr = w
+
+	remoteCmd := command
+	if c.become.Method == BecomeSudo {
+		remoteCmd = c.sudoCommand(fmt.Sprintf("printf '%%s\\n' %s >&2; %s", shellQuote(streamReadyMarker), command))
+	} else {
+		w.markReady()
+	}
+
+	if err := session.Start(remoteCmd); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("执行远程命令失败: %w", err)
+	}
+
+	stream := &streamSession{session: session, stdin: stdin, output: w, done: make(chan error, 1)}
+	go func() {
+		stream.done <- session.Wait()
+	}()
+
+	select {
+	case <-w.ready:
+		return stream, nil
+	case err := <-stream.done:
+		session.Close()
+		if err == nil {
+			err = fmt.Errorf("远程命令提前退出")
+		}
+		return nil, explainSudoError(w.String(), w.prompts, err)
+	}
+}
+
+// abort 终止远程命令，返回其输出和退出结果
+func (s *streamSession) abort() (string, error) {
+	s.session.Signal(ssh.SIGKILL)
+	s.session.Close()
+	err := <-s.done
+	if err == nil {
+		err = fmt.Errorf("远程命令已退出")
+	}
+	return s.output.String(), err
+}
+
+// streamWatcher 收集远程命令输出，应答 sudo 口令提示并检测就绪标记
+type streamWatcher struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	stdin    io.WriteCloser
+	password string
+	prompts  int
+	ready    chan struct{}
+	once     sync.Once
+}
+
+func (w *streamWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+
+	if bytes.HasSuffix(bytes.TrimRight(w.buf.Bytes(), " "), []byte(sudoPrompt)) {
+		w.prompts++
+		w.buf.Truncate(bytes.LastIndex(w.buf.Bytes(), []byte(sudoPrompt)))
+		if w.prompts == 1 && w.password != "" {
+			// sudo 逐字节读取口令，口令之后的标准输入原样交给 docker load
+			fmt.Fprintf(w.stdin, "%s\n", w.password)
+		} else {
+			w.stdin.Close()
+		}
+	}
+
+	if i := bytes.Index(w.buf.Bytes(), []byte(streamReadyMarker+"\n")); i >= 0 {
+		rest := append([]byte(nil), w.buf.Bytes()[i+len(streamReadyMarker)+1:]...)
+		w.buf.Truncate(i)
+		w.buf.Write(rest)
+		w.markReady()
+	}
+	return len(p), nil
+}
+
+// markReady 标记远程命令已就绪
+func (w *streamWatcher) markReady() {
+	w.once.Do(func() { close(w.ready) })
+}
+
+func (w *streamWatcher) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(w.buf.String())
+}
@@ -0,0 +1,347 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// 上传方式
+const (
+	UploadAuto = "auto" // 优先 SFTP，不可用时依次尝试 scp、cat
+	UploadSFTP = "sftp" // SFTP 子系统
+	UploadSCP  = "scp"  // SCP 协议（远程执行 scp -t）
+	UploadCat  = "cat"  // 通过 exec 会话执行 cat > 文件
+)
+
+// remoteWriter 远程文件写入器
+// Commit 完成写入并确认远程文件完整；Close 释放资源，未 Commit 时放弃本次写入
+// Abort 可以在写入阻塞时从其他 goroutine 调用，中止本次写入而不影响同一连接上的其他传输
+type remoteWriter interface {
+	io.Writer
+	Commit() error
+	Close() error
+	Abort()
+}
+
+// uploader 记录主机实际使用的上传方式，auto 模式下首次上传时确定
+type uploader struct {
+	mu     sync.Mutex
+	method string
+}
+
+// openRemote 按上传方式创建远程文件，offset 大于 0 时打开已有文件从该处续写
+// auto 模式下 SFTP 可用时连接阶段已确定使用 sftp；不可用时依次尝试 scp、cat 并记住可用的方式
+func (c *Client) openRemote(remotePath string, size, offset int64) (remoteWriter, error) {
+	c.uploader.mu.Lock()
+	defer c.uploader.mu.Unlock()
+
+	if c.uploader.method != UploadAuto {
+		return c.openRemoteWith(c.uploader.method, remotePath, size, offset)
+	}
+
+	var errs []string
+	for _, method := range []string{UploadSCP, UploadCat} {
+		if method == UploadSCP && (size < 0 || offset > 0) {
+			continue // scp 需要预先知道文件大小，也不能续写已有文件
+		}
+		w, err := c.openRemoteWith(method, remotePath, size, offset)
+		if err == nil {
+			c.uploader.method = method
+			return w, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", method, err))
+	}
+	return nil, fmt.Errorf("没有可用的上传方式（%s）", strings.Join(errs, "；"))
+}
+
+// openRemoteWith 使用指定方式创建远程文件
+func (c *Client) openRemoteWith(method, remotePath string, size, offset int64) (remoteWriter, error) {
+	switch method {
+	case UploadSFTP:
+		if c.sftpClient == nil {
+			return nil, fmt.Errorf("SFTP 子系统不可用")
+		}
+		return openSFTP(c.sshClient, remotePath, offset)
+	case UploadSCP:
+		if size < 0 {
+			return nil, fmt.Errorf("scp 需要预先知道文件大小，无法上传边读边压缩的数据，请改用 sftp 或 cat 上传")
+		}
+		if offset > 0 {
+			return nil, fmt.Errorf("scp 不支持续传")
+		}
+		return openSCP(c.sshClient, remotePath, size)
+	case UploadCat:
+		return openCat(c.sshClient, remotePath, offset)
+	default:
+		return nil, fmt.Errorf("不支持的上传方式: %s", method)
+	}
+}
+
+// UploadMethod 返回实际使用的上传方式，auto 模式下首次上传前返回 auto
+func (c *Client) UploadMethod() string {
+	c.uploader.mu.Lock()
+	defer c.uploader.mu.Unlock()
+	return c.uploader.method
+}
+
+// mkdirAll 创建远程目录，SFTP 不可用时通过 mkdir -p 创建
+func (c *Client) mkdirAll(dir string) error {
+	if c.sftpClient != nil {
+		return c.sftpClient.MkdirAll(dir)
+	}
+	if output, err := c.ExecuteCommand("mkdir -p " + shellQuote(dir)); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// sftpWriter 通过 SFTP 写入远程文件
+// 每次上传使用单独的 SFTP 会话（独立的通道），中止时只关闭该会话，不影响同一连接上的其他传输
+type sftpWriter struct {
+	*sftp.File
+	client *sftp.Client
+}
+
+// openSFTP 为本次上传打开新的 SFTP 会话并创建远程文件，offset 大于 0 时打开已有文件定位到 offset 处续写
+// 续写不使用 O_APPEND：部分 sftp-server 会忽略追加标志，仍按请求中的偏移写入
+func openSFTP(sshClient *ssh.Client, remotePath string, offset int64) (remoteWriter, error) {
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("创建SFTP会话失败: %w", err)
+	}
+
+	if offset == 0 {
+		file, err := client.Create(remotePath)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("创建远程文件失败: %w", err)
+		}
+		return &sftpWriter{File: file, client: client}, nil
+	}
+
+	file, err := client.OpenFile(remotePath, os.O_WRONLY)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("打开远程文件失败: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		client.Close()
+		return nil, fmt.Errorf("定位远程文件失败: %w", err)
+	}
+	return &sftpWriter{File: file, client: client}, nil
+}
+
+func (w *sftpWriter) Commit() error {
+	return w.File.Close()
+}
+
+func (w *sftpWriter) Close() error {
+	w.File.Close()
+	return w.client.Close()
+}
+
+// Abort 关闭本次上传的 SFTP 会话，阻塞中的写入随之返回
+// 对端不再响应时关闭会话会一直等待，因此在后台关闭，由停滞监控在会话仍未结束时关闭连接
+func (w *sftpWriter) Abort() {
+	go w.client.Close()
+}
+
+// execWriter 通过 exec 会话的标准输入写入远程文件
+type execWriter struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	output  *strings.Builder // 远程命令的错误输出
+	closed  bool
+}
+
+func (w *execWriter) Write(p []byte) (int, error) {
+	return w.stdin.Write(p)
+}
+
+func (w *execWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.session.Close()
+}
+
+// Abort 终止远程命令并关闭会话，阻塞中的写入随之返回
+func (w *execWriter) Abort() {
+	w.session.Signal(ssh.SIGKILL)
+	w.session.Close()
+}
+
+// wait 关闭标准输入并等待远程命令结束
+func (w *execWriter) wait() error {
+	w.stdin.Close()
+	if err := w.session.Wait(); err != nil {
+		if msg := strings.TrimSpace(w.output.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// startExec 创建 exec 会话并启动远程命令
+func startExec(client *ssh.Client, command string) (*execWriter, io.Reader, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, fmt.Errorf("创建标准输入失败: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, fmt.Errorf("创建标准输出失败: %w", err)
+	}
+	output := &strings.Builder{}
+	session.Stderr = output
+
+	if err := session.Start(command); err != nil {
+		session.Close()
+		return nil, nil, fmt.Errorf("执行远程命令失败: %w", err)
+	}
+	return &execWriter{session: session, stdin: stdin, output: output}, stdout, nil
+}
+
+// catWriter 通过 cat > 文件 写入，完成后核对远程文件大小
+type catWriter struct {
+	*execWriter
+	client     *ssh.Client
+	remotePath string
+	offset     int64 // 续传的起始位置
+	written    int64 // 已写入的字节数
+}
+
+// openCat 在远程执行 cat > 文件，offset 大于 0 时执行 cat >> 文件 续写
+func openCat(client *ssh.Client, remotePath string, offset int64) (remoteWriter, error) {
+	redirect := " > "
+	if offset > 0 {
+		redirect = " >> "
+	}
+	w, stdout, err := startExec(client, "cat"+redirect+shellQuote(remotePath))
+	if err != nil {
+		return nil, err
+	}
+	go io.Copy(io.Discard, stdout)
+	return &catWriter{execWriter: w, client: client, remotePath: remotePath, offset: offset}, nil
+}
+
+func (w *catWriter) Write(p []byte) (int, error) {
+	n, err := w.execWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *catWriter) Commit() error {
+	defer w.Close()
+	if err := w.wait(); err != nil {
+		return fmt.Errorf("cat 写入远程文件失败: %w", err)
+	}
+
+	// cat 没有确认机制，通过远程文件大小确认数据全部写入
+	remoteSize, err := execFileSize(w.client, w.remotePath)
+	if err != nil {
+		return err
+	}
+	if expected := w.offset + w.written; remoteSize != expected {
+		return fmt.Errorf("远程文件大小不一致: 期望 %d 字节，实际 %d 字节", expected, remoteSize)
+	}
+	return nil
+}
+
+// execFileSize 通过 wc -c 获取远程文件大小
+func execFileSize(client *ssh.Client, remotePath string) (int64, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput("wc -c < " + shellQuote(remotePath))
+	if err != nil {
+		return 0, fmt.Errorf("获取远程文件大小失败: %w", err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析远程文件大小失败: %q", strings.TrimSpace(string(output)))
+	}
+	return size, nil
+}
+
+// scpWriter 通过 SCP 协议（sink 模式）写入，远程逐步确认每个阶段
+type scpWriter struct {
+	*execWriter
+	acks *bufio.Reader
+}
+
+// openSCP 在远程执行 scp -t 并发送文件头
+func openSCP(client *ssh.Client, remotePath string, size int64) (remoteWriter, error) {
+	w, stdout, err := startExec(client, "scp -t "+shellQuote(remotePath))
+	if err != nil {
+		return nil, err
+	}
+	sw := &scpWriter{execWriter: w, acks: bufio.NewReader(stdout)}
+
+	// 远程 scp 启动后先发送一次确认；scp 未安装时这里会读到 EOF
+	if err := sw.readAck(); err != nil {
+		sw.Close()
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w.stdin, "C0644 %d %s\n", size, path.Base(remotePath)); err != nil {
+		sw.Close()
+		return nil, fmt.Errorf("发送 SCP 文件头失败: %w", err)
+	}
+	if err := sw.readAck(); err != nil {
+		sw.Close()
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (w *scpWriter) Commit() error {
+	defer w.Close()
+	if _, err := w.stdin.Write([]byte{0}); err != nil {
+		return fmt.Errorf("发送 SCP 结束标记失败: %w", err)
+	}
+	if err := w.readAck(); err != nil {
+		return err
+	}
+	if err := w.wait(); err != nil {
+		return fmt.Errorf("SCP 写入远程文件失败: %w", err)
+	}
+	return nil
+}
+
+// readAck 读取远程 scp 的确认：0 表示成功，1/2 后跟错误信息
+func (w *scpWriter) readAck() error {
+	b, err := w.acks.ReadByte()
+	if err != nil {
+		// 远程 scp 已退出，等待命令结束以获取完整的错误输出
+		if waitErr := w.wait(); waitErr != nil {
+			return fmt.Errorf("远程 scp 不可用: %w", waitErr)
+		}
+		return fmt.Errorf("远程 scp 不可用: %w", err)
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := w.acks.ReadString('\n')
+	return fmt.Errorf("远程 scp 返回错误: %s", strings.TrimSpace(msg))
+}
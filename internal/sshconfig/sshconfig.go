@@ -0,0 +1,438 @@
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth Include 的最大嵌套深度
+const maxIncludeDepth = 16
+
+// Config 解析后的 OpenSSH 客户端配置（~/.ssh/config）
+type Config struct {
+	blocks []block
+}
+
+// block 一个 Host 配置块
+type block struct {
+	patterns []string // Host 模式，nil 表示全局（第一个 Host 之前的配置）
+	match    bool     // Match 配置块（暂不支持，始终不匹配）
+	entries  []entry
+}
+
+// entry 一条配置项
+type entry struct {
+	key  string // 小写的关键字
+	args []string
+}
+
+// JumpHost ProxyJump 中的一跳
+type JumpHost struct {
+	User             string
+	Host             string
+	Port             int
+	IdentityFiles    []string // 该跳板机在 ssh_config 中配置的私钥文件
+	CertificateFiles []string // 该跳板机在 ssh_config 中配置的证书文件
+}
+
+// Host 针对某个主机别名解析出的配置
+type Host struct {
+	Alias            string     // 主机别名
+	HostName         string     // 实际连接的地址
+	User             string     // SSH用户名
+	Port             int        // SSH端口，未配置时为 0
+	IdentityFiles    []string   // 私钥文件
+	CertificateFiles []string   // 用户证书文件
+	ProxyJump        []JumpHost // 跳板链
+	Keys             []string   // 实际生效的配置项名称（用于展示）
+}
+
+// Load 解析 ssh_config 文件，文件不存在时返回空配置
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err := cfg.parseFile(path, nil, 0); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseFile 解析单个配置文件，patterns 为 Include 所在配置块的 Host 模式
+func (c *Config) parseFile(path string, patterns []string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("ssh_config Include 嵌套过深: %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取 ssh_config 失败: %w", err)
+	}
+	defer f.Close()
+
+	// 用下标引用当前配置块，Include 追加配置块时切片可能重新分配
+	c.blocks = append(c.blocks, block{patterns: patterns})
+	current := len(c.blocks) - 1
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		key, args, err := parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("解析 ssh_config 失败 [%s:%d]: %w", path, lineNum, err)
+		}
+		if key == "" {
+			continue
+		}
+
+		switch key {
+		case "host":
+			c.blocks = append(c.blocks, block{patterns: args})
+			current = len(c.blocks) - 1
+			patterns = args
+		case "match":
+			c.blocks = append(c.blocks, block{match: true})
+			current = len(c.blocks) - 1
+			patterns = nil
+		case "include":
+			if c.blocks[current].match {
+				continue
+			}
+			for _, pattern := range args {
+				files, err := filepath.Glob(includePath(pattern, path))
+				if err != nil {
+					return fmt.Errorf("解析 ssh_config Include 失败 [%s:%d]: %w", path, lineNum, err)
+				}
+				for _, file := range files {
+					if err := c.parseFile(file, patterns, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			// Include 之后的配置仍属于原来的 Host 块
+			c.blocks = append(c.blocks, block{patterns: patterns})
+			current = len(c.blocks) - 1
+		default:
+			c.blocks[current].entries = append(c.blocks[current].entries, entry{key: key, args: args})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 ssh_config 失败: %w", err)
+	}
+	return nil
+}
+
+// includePath 计算 Include 的文件路径，相对路径相对于 ~/.ssh（系统配置相对于 /etc/ssh）
+func includePath(pattern, from string) string {
+	pattern = expandHome(pattern)
+	if filepath.IsAbs(pattern) {
+		return pattern
+	}
+	if strings.HasPrefix(from, "/etc/ssh") {
+		return filepath.Join("/etc/ssh", pattern)
+	}
+	return filepath.Join(expandHome("~/.ssh"), pattern)
+}
+
+// parseLine 解析一行配置，返回小写关键字和参数，空行和注释返回空关键字
+func parseLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	// 关键字与参数之间可以用空白或 = 分隔
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	args, err := splitArgs(rest)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, args, nil
+}
+
+// splitArgs 按空白拆分参数，支持双引号
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inQuote, hasArg := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				hasArg = false
+			}
+		case r == '#' && !inQuote && !hasArg:
+			// 行尾注释
+			return args, nil
+		default:
+			cur.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("引号不匹配")
+	}
+	if hasArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Resolve 解析主机别名对应的配置，规则与 OpenSSH 一致：每个配置项以第一次出现的值为准
+func (c *Config) Resolve(alias string) (*Host, error) {
+	return c.resolve(alias, 0)
+}
+
+func (c *Config) resolve(alias string, depth int) (*Host, error) {
+	host := &Host{Alias: alias}
+	seen := make(map[string]bool)
+	var proxyJump string
+
+	for _, b := range c.blocks {
+		if !b.matches(alias) {
+			continue
+		}
+		for _, e := range b.entries {
+			if len(e.args) == 0 {
+				continue
+			}
+			// IdentityFile 和 CertificateFile 可以出现多次并累加，其余配置项以第一次出现的值为准
+			if e.key != "identityfile" && e.key != "certificatefile" && seen[e.key] {
+				continue
+			}
+
+			switch e.key {
+			case "hostname":
+				host.HostName = e.args[0]
+			case "user":
+				host.User = e.args[0]
+			case "port":
+				port, err := strconv.Atoi(e.args[0])
+				if err != nil || port <= 0 || port > 65535 {
+					return nil, fmt.Errorf("ssh_config 中主机 %s 的端口无效: %s", alias, e.args[0])
+				}
+				host.Port = port
+			case "identityfile":
+				host.IdentityFiles = append(host.IdentityFiles, e.args[0])
+			case "certificatefile":
+				host.CertificateFiles = append(host.CertificateFiles, e.args[0])
+			case "proxyjump":
+				proxyJump = e.args[0]
+			default:
+				continue
+			}
+			if !seen[e.key] {
+				host.Keys = append(host.Keys, canonicalKey(e.key))
+			}
+			seen[e.key] = true
+		}
+	}
+
+	// 展开 HostName、IdentityFile 和 CertificateFile 中的 % 变量
+	if host.HostName != "" {
+		host.HostName = strings.ReplaceAll(host.HostName, "%h", alias)
+	}
+	for i, file := range host.IdentityFiles {
+		host.IdentityFiles[i] = expandHome(expandTokens(file, host))
+	}
+	for i, file := range host.CertificateFiles {
+		host.CertificateFiles[i] = expandHome(expandTokens(file, host))
+	}
+
+	if proxyJump != "" && !strings.EqualFold(proxyJump, "none") {
+		hops, err := c.resolveJump(proxyJump, depth)
+		if err != nil {
+			return nil, err
+		}
+		host.ProxyJump = hops
+	}
+	return host, nil
+}
+
+// resolveJump 解析 ProxyJump，每一跳同样按 ssh_config 解析（包括其自身的 ProxyJump）
+func (c *Config) resolveJump(value string, depth int) ([]JumpHost, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("ssh_config ProxyJump 嵌套过深: %s", value)
+	}
+
+	var hops []JumpHost
+	for _, spec := range strings.Split(value, ",") {
+		hop, err := parseJumpSpec(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+
+		resolved, err := c.resolve(hop.Host, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, resolved.ProxyJump...)
+
+		if resolved.HostName != "" {
+			hop.Host = resolved.HostName
+		}
+		if hop.User == "" {
+			hop.User = resolved.User
+		}
+		if hop.Port == 0 {
+			hop.Port = resolved.Port
+		}
+		hop.IdentityFiles = resolved.IdentityFiles
+		hop.CertificateFiles = resolved.CertificateFiles
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// parseJumpSpec 解析 [user@]host[:port] 格式的跳板机，IPv6 地址需要写成 [addr]:port
+func parseJumpSpec(spec string) (JumpHost, error) {
+	var hop JumpHost
+	spec = strings.TrimPrefix(spec, "ssh://")
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		hop.User = spec[:i]
+		spec = spec[i+1:]
+	}
+
+	hop.Host = spec
+	if host, port, err := net.SplitHostPort(spec); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return hop, fmt.Errorf("ProxyJump 端口无效: %s", spec)
+		}
+		hop.Host, hop.Port = host, p
+	} else if strings.HasPrefix(spec, "[") && strings.HasSuffix(spec, "]") {
+		hop.Host = spec[1 : len(spec)-1]
+	}
+	if hop.Host == "" {
+		return hop, fmt.Errorf("ProxyJump 地址为空")
+	}
+	return hop, nil
+}
+
+// matches 判断配置块是否适用于主机别名，与 OpenSSH 一样不区分大小写
+func (b *block) matches(alias string) bool {
+	if b.match {
+		return false
+	}
+	if b.patterns == nil {
+		return true
+	}
+
+	alias = strings.ToLower(alias)
+	matched := false
+	for _, pattern := range b.patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "!") {
+			if matchPattern(pattern[1:], alias) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(pattern, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern 通配符匹配，仅支持 * 和 ?（与 OpenSSH 一致）
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// expandTokens 展开 IdentityFile 中的 %d %u %h %r %% 变量
+func expandTokens(s string, host *Host) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	hostname := host.HostName
+	if hostname == "" {
+		hostname = host.Alias
+	}
+	localUser := ""
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+	}
+	home, _ := os.UserHomeDir()
+
+	replacer := strings.NewReplacer(
+		"%%", "%",
+		"%d", home,
+		"%u", localUser,
+		"%h", hostname,
+		"%r", host.User,
+	)
+	return replacer.Replace(s)
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// canonicalKey 返回配置项的标准写法
+func canonicalKey(key string) string {
+	switch key {
+	case "hostname":
+		return "HostName"
+	case "user":
+		return "User"
+	case "port":
+		return "Port"
+	case "identityfile":
+		return "IdentityFile"
+	case "proxyjump":
+		return "ProxyJump"
+	case "certificatefile":
+		return "CertificateFile"
+	}
+	return key
+}
@@ -0,0 +1,214 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// writeConfig 在临时目录中写入配置文件，返回文件路径
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入 %s: %v", name, err)
+	}
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config", `
+# 注释
+Host bastion
+    HostName 203.0.113.1
+    User jump
+    Port 2200
+
+Host web* !web-legacy
+    HostName %h.internal
+    User deploy
+    ProxyJump bastion
+
+Host Web-Upper*
+    Port 2222
+
+Host db1
+    HostName=10.0.2.1
+    Port 5522
+    IdentityFile "~/.ssh/db key"
+
+Host *
+    User fallback
+    Port 22
+    IdentityFile ~/.ssh/id_ed25519
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		alias     string
+		hostName  string
+		user      string
+		port      int
+		proxyJump []string // 跳板链的 user@host:port
+	}{
+		// 第一次出现的值生效，Host * 中的 User 不覆盖前面的配置
+		{alias: "web1", hostName: "web1.internal", user: "deploy", port: 22, proxyJump: []string{"jump@203.0.113.1:2200"}},
+		// 否定模式排除主机，只匹配 Host *
+		{alias: "web-legacy", user: "fallback", port: 22},
+		// 模式和主机名都不区分大小写
+		{alias: "WEB2", hostName: "WEB2.internal", user: "deploy", port: 22, proxyJump: []string{"jump@203.0.113.1:2200"}},
+		{alias: "web-upper1", hostName: "web-upper1.internal", user: "deploy", port: 2222, proxyJump: []string{"jump@203.0.113.1:2200"}},
+		// 关键字与参数之间可以用 = 分隔
+		{alias: "db1", hostName: "10.0.2.1", user: "fallback", port: 5522},
+		{alias: "other", user: "fallback", port: 22},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			host, err := cfg.Resolve(tt.alias)
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.alias, err)
+			}
+			if host.HostName != tt.hostName || host.User != tt.user || host.Port != tt.port {
+				t.Errorf("Resolve(%q) = {HostName: %q, User: %q, Port: %d}, want {HostName: %q, User: %q, Port: %d}",
+					tt.alias, host.HostName, host.User, host.Port, tt.hostName, tt.user, tt.port)
+			}
+			var hops []string
+			for _, hop := range host.ProxyJump {
+				hops = append(hops, hop.User+"@"+hop.Host+":"+strconv.Itoa(hop.Port))
+			}
+			if !reflect.DeepEqual(hops, tt.proxyJump) {
+				t.Errorf("Resolve(%q).ProxyJump = %v, want %v", tt.alias, hops, tt.proxyJump)
+			}
+		})
+	}
+}
+
+func TestResolveIdentityFilesAccumulate(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config", `
+Host db1
+    IdentityFile /keys/db
+    IdentityFile "/keys/db two"
+
+Host *
+    IdentityFile /keys/%r@%h
+    User ops
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	host, err := cfg.Resolve("db1")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	want := []string{"/keys/db", "/keys/db two", "/keys/ops@db1"}
+	if !reflect.DeepEqual(host.IdentityFiles, want) {
+		t.Errorf("IdentityFiles = %v, want %v", host.IdentityFiles, want)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hosts.conf", `
+Host app1
+    HostName 10.0.1.1
+`)
+	path := writeConfig(t, dir, "config", `
+Host app*
+    Include `+filepath.Join(dir, "hosts.conf")+`
+    User included
+
+Host app1
+    HostName 10.9.9.9
+    User later
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	host, err := cfg.Resolve("app1")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	// Include 的内容按出现位置参与第一次匹配优先，Include 之后的配置仍属于原来的 Host 块
+	if host.HostName != "10.0.1.1" || host.User != "included" {
+		t.Errorf("Resolve(app1) = {HostName: %q, User: %q}, want {HostName: %q, User: %q}",
+			host.HostName, host.User, "10.0.1.1", "included")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	host, err := cfg.Resolve("web1")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if host.HostName != "" || host.User != "" || host.Port != 0 {
+		t.Errorf("空配置解析出了配置项: %+v", host)
+	}
+}
+
+func TestInvalidPort(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), "config", "Host web1\n    Port 70000\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := cfg.Resolve("web1"); err == nil {
+		t.Error("Resolve 应返回端口无效的错误")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"web?", "web1", true},
+		{"web?", "web10", false},
+		{"*.example.com", "a.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"10.0.*.1", "10.0.3.1", true},
+		{"db", "db1", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseJumpSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want JumpHost
+	}{
+		{"bastion", JumpHost{Host: "bastion"}},
+		{"jump@bastion:2200", JumpHost{User: "jump", Host: "bastion", Port: 2200}},
+		{"ssh://jump@bastion", JumpHost{User: "jump", Host: "bastion"}},
+		{"[fd00::1]:22", JumpHost{Host: "fd00::1", Port: 22}},
+		{"[fd00::1]", JumpHost{Host: "fd00::1"}},
+	}
+	for _, tt := range tests {
+		got, err := parseJumpSpec(tt.spec)
+		if err != nil {
+			t.Errorf("parseJumpSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJumpSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
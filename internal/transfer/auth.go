@@ -0,0 +1,132 @@
+package transfer
+
+import (
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"strings"
+	"time"
+)
+
+// initAuth 初始化所有目标主机和跳板机的认证器
+// 提前加载全部凭据，避免在并发传输过程中提示输入口令；私钥和 ssh-agent 连接由所有认证器共享，口令只需提供一次
+func (m *Manager) initAuth() error {
+	m.keyring = ssh.NewKeyring(m.cfg.SSH.PassphraseEnv, m.cfg.SSH.PassphraseFile)
+	m.auths = make(map[string]*ssh.Authenticator)
+
+	for _, target := range m.cfg.Targets {
+		if _, err := m.authFor(target.KeyFiles, target.CertFiles, target.Password); err != nil {
+			return err
+		}
+		for _, hop := range target.JumpHosts {
+			if _, err := m.hopAuth(hop); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// authFor 返回指定凭据的认证器，相同凭据只创建一次，私钥从共享的 keyring 中获取
+func (m *Manager) authFor(keyFiles, certFiles []string, password string) (*ssh.Authenticator, error) {
+	key := strings.Join(keyFiles, "\x00") + "\x01" + strings.Join(certFiles, "\x00") + "\x01" + password
+
+	m.authMu.Lock()
+	defer m.authMu.Unlock()
+
+	if auth, ok := m.auths[key]; ok {
+		return auth, nil
+	}
+
+	auth := ssh.NewAuthenticator(ssh.AuthConfig{
+		Methods:   m.cfg.SSH.AuthMethods,
+		KeyFiles:  keyFiles,
+		CertFiles: certFiles,
+		Password:  password,
+		Keyring:   m.keyring,
+	})
+	if err := auth.Load(); err != nil {
+		return nil, err
+	}
+	m.auths[key] = auth
+	return auth, nil
+}
+
+// closeAuth 关闭共享的 ssh-agent 连接
+func (m *Manager) closeAuth() {
+	m.keyring.Close()
+}
+
+// hopAuth 返回跳板机使用的认证器，未单独配置凭据时沿用全局凭据
+func (m *Manager) hopAuth(hop config.JumpHostConfig) (*ssh.Authenticator, error) {
+	if hop.KeyFile == "" && hop.Password == "" {
+		return m.authFor(m.cfg.SSH.AllKeyFiles(), m.cfg.SSH.CertFiles(), m.cfg.SSH.Password)
+	}
+	var keyFiles, certFiles []string
+	if hop.KeyFile != "" {
+		keyFiles = append(keyFiles, hop.KeyFile)
+	}
+	if hop.CertFile != "" {
+		certFiles = append(certFiles, hop.CertFile)
+	}
+	return m.authFor(keyFiles, certFiles, hop.Password)
+}
+
+// clientOptions 构建目标主机的SSH连接参数
+func (m *Manager) clientOptions(target config.Target) (ssh.ClientOptions, error) {
+	auth, err := m.authFor(target.KeyFiles, target.CertFiles, target.Password)
+	if err != nil {
+		return ssh.ClientOptions{}, err
+	}
+	jumpHosts, err := m.jumpEndpoints(target)
+	if err != nil {
+		return ssh.ClientOptions{}, err
+	}
+	return ssh.ClientOptions{
+		Name: target.Name,
+		Endpoint: ssh.Endpoint{
+			Host: target.Address,
+			Port: target.Port,
+			User: target.User,
+			Auth: auth,
+		},
+		JumpHosts:    jumpHosts,
+		Dialer:       m.dialer,
+		StallTimeout: time.Duration(m.cfg.Transfer.StallTimeout) * time.Second,
+		Bandwidth:    m.bandwidth,
+		UploadMethod: m.cfg.Transfer.UploadMethod,
+		Become: ssh.Become{
+			Method:   target.Become.Method,
+			User:     target.Become.User,
+			Password: target.Become.Password,
+			Hooks:    target.Become.Hooks,
+		},
+	}, nil
+}
+
+// jumpEndpoints 返回目标主机使用的跳板链
+func (m *Manager) jumpEndpoints(target config.Target) ([]ssh.Endpoint, error) {
+	endpoints := make([]ssh.Endpoint, 0, len(target.JumpHosts))
+	for _, hop := range target.JumpHosts {
+		auth, err := m.hopAuth(hop)
+		if err != nil {
+			return nil, err
+		}
+		ep := ssh.Endpoint{
+			Host: hop.Address,
+			Port: hop.Port,
+			User: hop.User,
+			Auth: auth,
+		}
+		if ep.Port == 0 {
+			ep.Port = 22
+		}
+		if ep.User == "" {
+			ep.User = m.cfg.SSH.User
+		}
+		if ep.User == "" {
+			ep.User = target.User
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
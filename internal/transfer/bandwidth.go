@@ -0,0 +1,70 @@
+package transfer
+
+import (
+	"dockship/internal/bandwidth"
+	"dockship/internal/config"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// newLimiter 根据配置创建上传限速器
+// 未配置任何限速时同样创建（速率为 0 即不限速），运行中收到 SIGHUP 时可以再加上限速
+func newLimiter(cfg *config.Config) *bandwidth.Limiter {
+	return bandwidth.New(cfg.Transfer.BandwidthRate, hostLimits(cfg))
+}
+
+// hostLimits 按主机名称汇总单独配置的限速
+func hostLimits(cfg *config.Config) map[string]int64 {
+	limits := make(map[string]int64)
+	for _, target := range cfg.Targets {
+		if target.BandwidthLimit > 0 {
+			limits[target.Name] = target.BandwidthLimit
+		}
+	}
+	return limits
+}
+
+// watchReload 收到 SIGHUP 时重新读取配置文件中的限速，立即应用到进行中的上传
+// 只读取限速，其他配置的修改在下次运行时生效；启动时没有配置限速也监听，
+// 否则 SIGHUP 的默认行为会终止进程。返回的函数用于停止监听
+func (m *Manager) watchReload() func() {
+	if m.cfg.ConfigFile == "" {
+		return func() {}
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				total, limits, err := config.LoadBandwidthLimits(m.cfg.ConfigFile)
+				if err != nil {
+					m.notify("⚠️  重新读取限速失败，继续使用原来的限速: %v\n", err)
+					continue
+				}
+				m.bandwidth.Update(total, limits)
+				m.notify("🔄 已更新上传限速: 总计 %s，单独限速 %d 台\n", config.FormatBandwidth(total), len(limits))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// notify 输出运行中的提示：正在传输镜像时输出到进度条上方，避免打乱进度条
+func (m *Manager) notify(format string, args ...any) {
+	if progress := m.progress.Load(); progress != nil {
+		fmt.Fprintf(progress, "  "+format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
+}
@@ -0,0 +1,226 @@
+package transfer
+
+import (
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// multicastToHosts 批量分发：按并发数将主机分批，每批只读取一次镜像数据（tar 文件或 docker save），同时传输到批内所有主机
+// 成功的主机写入 results 并将 attempts 置为 0；失败的主机保留剩余的尝试次数，被脱离的主机保留全部尝试次数，随后单独传输
+func (m *Manager) multicastToHosts(targets []config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress, results []TransferResult, attempts []int) {
+	batchSize := m.cfg.Transfer.Concurrent
+	for start := 0; start < len(targets); start += batchSize {
+		end := min(start+batchSize, len(targets))
+		stats, errs := m.multicastBatch(targets[start:end], imageCfg, tarFile, progress)
+
+		for i, err := range errs {
+			index := start + i
+			target := targets[index]
+			switch {
+			case err == nil:
+				results[index] = TransferResult{Host: target.Name, Image: imageCfg.Name, Success: true, Stats: stats[i]}
+				attempts[index] = 0
+			case errors.Is(err, errUpToDate):
+				results[index] = TransferResult{Host: target.Name, Image: imageCfg.Name, Success: true, Skipped: true}
+				attempts[index] = 0
+			case errors.Is(err, errUnicast):
+				// 远程不支持批内的压缩算法，或者只需要部分层，单独传输
+			case isDetached(err):
+				// 被脱离的主机不计入尝试次数，流式传输时本次尝试的 pre_load hooks 已在批量分发前执行
+				if m.streaming() {
+					m.preLoaded.Store(preLoadKey(target, imageCfg), struct{}{})
+				}
+				fmt.Fprintf(progress, "  🐢 [%s] %v，稍后单独传输\n", target.Name, err)
+			case attempts[index] > 1:
+				attempts[index]--
+				fmt.Fprintf(progress, "  ⚠️  [%s] 批量分发失败: %v，稍后单独重试\n", target.Name, err)
+			default:
+				results[index] = TransferResult{Host: target.Name, Image: imageCfg.Name, Error: err}
+				attempts[index] = 0
+			}
+		}
+	}
+}
+
+// multicastBatch 对一批主机执行一次批量分发，返回每台主机的传输统计和错误
+func (m *Manager) multicastBatch(targets []config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) ([]*TransferStats, []error) {
+	stats := make([]*TransferStats, len(targets))
+	errs := make([]error, len(targets))
+	clients := make([]*ssh.Client, len(targets))
+	codec := m.cfg.Transfer.Compression
+
+	// 1. 并发建立连接（流式传输时同时执行 pre_load hooks），全部就绪后再开始读取数据，
+	// 避免连接慢的主机在开始前就被判定为接收过慢
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target config.Target) {
+			defer wg.Done()
+			client, err := m.client(target, progress)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if m.upToDate(client, imageCfg) {
+				errs[i] = errUpToDate
+				return
+			}
+			// 整批共用一次压缩和同一份完整归档，无法使用该压缩算法或可以增量传输的主机不参与批量分发；
+			// 单独限速的主机也不参与，否则整批都会被拖慢到它的速率
+			if target.BandwidthLimit > 0 || m.compressionFor(target, client, progress) != codec || m.deltaFor(client, target, tarFile, progress) != nil || m.hasBasis(client, imageCfg) {
+				errs[i] = errUnicast
+				return
+			}
+			if m.streaming() {
+				m.runPreLoad(client, target, imageCfg)
+			}
+			clients[i] = client
+		}(i, target)
+	}
+	wg.Wait()
+
+	var ready []int
+	for i, client := range clients {
+		if client != nil {
+			ready = append(ready, i)
+		}
+	}
+	if len(ready) == 0 {
+		return stats, errs
+	}
+	failReady := func(err error) ([]*TransferStats, []error) {
+		for _, i := range ready {
+			errs[i] = err
+		}
+		return stats, errs
+	}
+
+	// 2. 打开数据源：file 模式读取 tar 文件，stream 模式启动一次 docker save；开启压缩时整批只压缩一次
+	src, size, closeSrc, err := m.openSource(imageCfg, tarFile)
+	if err != nil {
+		return failReady(err)
+	}
+	ws, err := newWireSource(src, codec, m.cfg.Transfer.CompressionLevel)
+	if err != nil {
+		closeSrc()
+		return failReady(err)
+	}
+	if ws.compressed() {
+		size = -1 // 压缩后的大小未知
+	}
+
+	// 整批共用一次校验和计算
+	var data io.Reader = ws
+	var sum *checksum
+	if m.verifying() {
+		sum = newChecksum(ws)
+		data = sum
+	}
+
+	mc := newMulticast(data, len(ready), m.cfg.Transfer.MulticastBuffer*1024*1024, time.Duration(m.cfg.Transfer.SlowHostTimeout)*time.Second)
+	pumpDone := make(chan struct{})
+	go func() {
+		defer close(pumpDone)
+		mc.run()
+	}()
+
+	// 3. 每台主机从各自的读取端接收数据
+	for j, i := range ready {
+		wg.Add(1)
+		go func(i int, r *multicastReader) {
+			defer wg.Done()
+			defer r.Close()
+
+			err := m.multicastToHost(clients[i], targets[i], imageCfg, tarFile, codec, r, size, sum, progress)
+			if err != nil && r.detached() {
+				err = errDetached
+			}
+			if err == nil {
+				stats[i] = hostStats(ws, r)
+				stats[i].Verified = sum != nil
+			}
+			errs[i] = err
+		}(i, mc.reader(j))
+	}
+	wg.Wait()
+
+	// 所有主机结束后关闭数据源，读取被阻塞的分发协程随之退出
+	ws.Close()
+	closeSrc()
+	<-pumpDone
+	return stats, errs
+}
+
+// multicastToHost 从批量分发的读取端接收数据并传输到单台主机
+// sum 为整批数据的校验和，主机读取完全部数据后即可得到
+func (m *Manager) multicastToHost(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, tarFile, codec string, r io.Reader, size int64, sum *checksum, progress *mpb.Progress) error {
+	if m.streaming() {
+		if err := sshClient.LoadDockerImageStream(r, max(size, 0), codec, progress); err != nil {
+			return err
+		}
+		m.runHooks(sshClient, "post_load", m.cfg.Hooks.PostLoad, imageCfg.Hooks.PostLoad, imageCfg)
+		return nil
+	}
+
+	remoteTarPath := uploadPath(target, tarFile, codec)
+	if err := sshClient.UploadReader(r, size, remoteTarPath, progress); err != nil {
+		return err
+	}
+	if err := m.verifyUpload(sshClient, target, remoteTarPath, sum, progress); err != nil {
+		return err
+	}
+	return m.loadUploaded(sshClient, target, imageCfg, remoteTarPath, codec, m.cacheSource(tarFile, codec), progress)
+}
+
+// hostStats 返回批量分发中单台主机的传输统计，字节数和耗时按该主机的读取端计算
+// 读取端收到的是压缩后的数据，压缩时原始大小取整批数据源的原始字节数（主机成功时已收到全部数据）
+func hostStats(ws *wireSource, r *multicastReader) *TransferStats {
+	raw := r.n
+	if ws.compressed() {
+		raw = ws.raw.n.Load()
+	}
+	return &TransferStats{
+		Compression: ws.codec,
+		RawBytes:    raw,
+		WireBytes:   r.n,
+		Elapsed:     r.elapsed(),
+	}
+}
+
+// openSource 打开批量分发的数据源，返回数据流、数据大小（stream 模式为估算值）和关闭函数
+func (m *Manager) openSource(imageCfg config.ImageConfig, tarFile string) (io.Reader, int64, func(), error) {
+	if m.streaming() {
+		stream, err := m.dockerClient.SaveImageStream(imageCfg.Name)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return stream, m.dockerClient.ImageSize(imageCfg.Name), func() { stream.Close() }, nil
+	}
+
+	file, err := os.Open(tarFile)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, nil, fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	return file, info.Size(), func() { file.Close() }, nil
+}
+
+// errUnicast 主机需要单独传输：实际使用的压缩算法与批量分发的不同，或者只需要发送部分层或差异
+var errUnicast = errors.New("需要单独传输")
+
+// isDetached 判断主机是否因接收过慢被脱离批量分发
+func isDetached(err error) bool {
+	return errors.Is(err, errDetached)
+}
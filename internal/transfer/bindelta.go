@@ -0,0 +1,302 @@
+package transfer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"dockship/internal/compress"
+	"dockship/internal/config"
+	"dockship/internal/delta"
+	"dockship/internal/ssh"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// binaryDelta 是否启用二进制增量传输
+func (m *Manager) binaryDelta() bool {
+	return m.cfg.Transfer.BinaryDelta && !m.streaming()
+}
+
+// cachePath 远程缓存中镜像仓库上一次传输的 tar 文件，作为下次计算增量的基准文件
+// 同一仓库的不同标签共用一个基准文件，新版本与旧版本通常只有少数层不同；同时传输时由 lockCache 依次使用和更新
+func (m *Manager) cachePath(imageCfg config.ImageConfig) string {
+	return path.Join(m.cfg.RemoteStorage.CacheDir, cacheName(imageCfg.Name)+".tar")
+}
+
+// localSignaturePath 本地缓存的基准文件签名，不使用远程辅助程序时使用
+func (m *Manager) localSignaturePath(target config.Target, imageCfg config.ImageConfig) string {
+	return filepath.Join(m.cfg.LocalStorage.CacheDir, cacheName(target.Name), cacheName(imageCfg.Name)+".sig")
+}
+
+// cacheName 去掉镜像名中的标签和摘要，并替换文件名中不能使用的字符
+func cacheName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return strings.NewReplacer("/", "_", ":", "_").Replace(image)
+}
+
+// cacheSource 上传的是完整的未压缩 tar 文件时，返回需要保存到远程缓存的本地文件，否则返回空字符串
+func (m *Manager) cacheSource(tarFile, codec string) string {
+	if !m.binaryDelta() || codec != compress.None {
+		return ""
+	}
+	return tarFile
+}
+
+// hasBasis 远程缓存中是否有可用于二进制增量的基准文件
+func (m *Manager) hasBasis(sshClient *ssh.Client, imageCfg config.ImageConfig) bool {
+	if !m.binaryDelta() {
+		return false
+	}
+	size, err := sshClient.RemoteFileSize(m.cachePath(imageCfg))
+	return err == nil && size > 0
+}
+
+// deltaHelper 返回远程主机上的增量辅助程序，未开启或无法使用时返回空字符串
+// 辅助程序是 dockship 自身，首次上传的大小与完整程序相同，因此需要通过 transfer.delta_helper 显式开启；
+// 尚未上传且程序比 saving（本次可能节省的流量）还大时不上传，改用本地签名和 shell 重建，之后更大的镜像仍会尝试安装
+func (m *Manager) deltaHelper(sshClient *ssh.Client, target config.Target, saving int64, progress *mpb.Progress) string {
+	if !m.cfg.Transfer.DeltaHelper {
+		return ""
+	}
+	hc := m.pool.entry(target.Name)
+	hc.helperMu.Lock()
+	defer hc.helperMu.Unlock()
+	if hc.helper != "" || hc.helperFailed {
+		return hc.helper
+	}
+
+	helper, err := sshClient.InstallHelper(m.cfg.RemoteStorage.CacheDir, saving, progress)
+	switch {
+	case errors.Is(err, ssh.ErrHelperTooLarge):
+		fmt.Fprintf(progress, "  ℹ️  [%s] %v，改用本地签名和 shell 重建\n", target.Name, err)
+	case err != nil:
+		fmt.Fprintf(progress, "  ⚠️  [%s] %v，改用本地签名和 shell 重建\n", target.Name, err)
+		hc.helperFailed = true
+	default:
+		hc.helper = helper
+	}
+	return hc.helper
+}
+
+// lockCache 锁定主机上仓库的基准文件和本地签名，返回解锁函数
+// 同一仓库的多个标签同时传输到同一主机时，计算增量和重建期间基准文件不会被另一个标签替换
+func (m *Manager) lockCache(target config.Target, imageCfg config.ImageConfig) func() {
+	value, _ := m.cacheLocks.LoadOrStore(target.Name+"\x00"+cacheName(imageCfg.Name), &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// sendBinaryDelta 远程缓存中有该仓库上一次传输的 tar 文件时，只上传与它不同的数据，在远程重建新的 tar 文件
+// 返回 nil 表示无法增量传输（没有基准文件、签名不可用、增量过大或重建失败），由调用方上传完整文件
+func (m *Manager) sendBinaryDelta(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) (*TransferStats, error) {
+	defer m.lockCache(target, imageCfg)()
+
+	basis := m.cachePath(imageCfg)
+	basisSize, err := sshClient.RemoteFileSize(basis)
+	if err != nil || basisSize == 0 {
+		return nil, nil
+	}
+
+	// 1. 获取基准文件的签名：远程辅助程序计算，或使用上次传输时在本地计算并缓存的签名
+	// 增量节省的流量不会超过基准文件的大小，辅助程序比基准文件还大时不上传
+	helper := m.deltaHelper(sshClient, target, basisSize, progress)
+	sig := m.basisSignature(sshClient, target, imageCfg, helper, basis, progress)
+	if sig == nil {
+		return nil, nil
+	}
+
+	// 2. 在本地计算增量
+	start := time.Now()
+	deltaFile := fmt.Sprintf("%s.%s.delta", tarFile, cacheName(target.Name))
+	defer os.Remove(deltaFile)
+	res, err := diffFile(sig, tarFile, deltaFile)
+	if err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 计算二进制增量失败: %v，传输完整镜像\n", target.Name, err)
+		return nil, nil
+	}
+	if res.DeltaSize >= res.Size*9/10 {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 与远程缓存的镜像差异过大（增量 %s / %s），传输完整镜像\n",
+			target.Name, formatBytes(res.DeltaSize), formatBytes(res.Size))
+		return nil, nil
+	}
+
+	// 3. 上传增量并在远程重建 tar 文件
+	remoteTarPath := uploadPath(target, tarFile, compress.None)
+	remoteDelta := remoteTarPath + ".delta"
+	if err := sshClient.UploadFile(deltaFile, remoteDelta, progress); err != nil {
+		return nil, err
+	}
+	defer sshClient.RemoveRemoteFile(remoteDelta)
+
+	if err := m.rebuild(sshClient, helper, basis, deltaFile, remoteDelta, remoteTarPath, res, progress); err != nil {
+		sshClient.RemoveRemoteFile(remoteTarPath)
+		fmt.Fprintf(progress, "  ⚠️  [%s] %v，传输完整镜像\n", target.Name, err)
+		return nil, nil
+	}
+
+	return &TransferStats{
+		RawBytes:    res.Size,
+		WireBytes:   res.DeltaSize,
+		Elapsed:     time.Since(start),
+		Verified:    true, // 重建时已校验新文件的 SHA-256
+		BinaryDelta: true,
+	}, nil
+}
+
+// basisSignature 获取远程基准文件的签名，都不可用时返回 nil
+// 本地缓存的签名只有在远程基准文件的 SHA-256 与记录一致时才使用；远程没有 sha256sum 时不使用增量，
+// 通过 SFTP 读回整个基准文件校验的流量比直接传输完整镜像还多
+func (m *Manager) basisSignature(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, helper, basis string, progress *mpb.Progress) *delta.Signature {
+	if helper != "" {
+		sig, err := sshClient.DeltaSignature(helper, basis)
+		if err == nil {
+			return sig
+		}
+		fmt.Fprintf(progress, "  ⚠️  [%s] %v\n", target.Name, err)
+	}
+
+	sum, sig, err := readLocalSignature(m.localSignaturePath(target, imageCfg))
+	if err != nil {
+		return nil
+	}
+	if err := sshClient.VerifyChecksumInPlace(basis, sum); err != nil {
+		if !errors.Is(err, ssh.ErrChecksumMismatch) {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 无法在远程校验基准文件: %v，传输完整镜像\n", target.Name, err)
+		}
+		return nil
+	}
+	return sig
+}
+
+// rebuild 在远程用基准文件和增量重建 tar 文件
+// 辅助程序重建时自行校验新文件；shell 重建不校验，完成后再校验远程文件的 SHA-256
+func (m *Manager) rebuild(sshClient *ssh.Client, helper, basis, deltaFile, remoteDelta, remoteTarPath string, res *delta.Result, progress *mpb.Progress) error {
+	// 未开启远程清理时，输出文件可能仍是基准文件的硬链接，先删除，避免写入时截断基准文件
+	sshClient.RemoveRemoteFile(remoteTarPath)
+
+	if helper != "" {
+		return sshClient.DeltaPatch(helper, basis, remoteDelta, remoteTarPath)
+	}
+
+	file, err := os.Open(deltaFile)
+	if err != nil {
+		return fmt.Errorf("打开增量文件失败: %w", err)
+	}
+	defer file.Close()
+	script, err := delta.Script(file, basis, remoteDelta, remoteTarPath)
+	if err != nil {
+		return err
+	}
+	if err := sshClient.RunScript(script, remoteTarPath+".sh", progress); err != nil {
+		return fmt.Errorf("远程重建镜像文件失败: %w", err)
+	}
+	return sshClient.VerifyChecksum(remoteTarPath, res.Sum)
+}
+
+// updateCache 镜像加载成功后将远程 tar 文件保存为该仓库的基准文件
+// 不使用远程辅助程序时，同时在本地缓存基准文件的签名
+func (m *Manager) updateCache(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, tarFile, remoteTarPath string, progress *mpb.Progress) {
+	defer m.lockCache(target, imageCfg)()
+
+	if err := sshClient.LinkFile(remoteTarPath, m.cachePath(imageCfg)); err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 保存远程缓存失败: %v\n", target.Name, err)
+		return
+	}
+
+	// 下次传输时基准文件就是这次的 tar 文件
+	if info, err := os.Stat(tarFile); err == nil && m.deltaHelper(sshClient, target, info.Size(), progress) != "" {
+		return
+	}
+	if err := writeLocalSignature(m.localSignaturePath(target, imageCfg), tarFile); err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 缓存本地签名失败: %v\n", target.Name, err)
+	}
+}
+
+// diffFile 计算 tarFile 相对签名的增量，写入 deltaFile
+func diffFile(sig *delta.Signature, tarFile, deltaFile string) (*delta.Result, error) {
+	src, err := os.Open(tarFile)
+	if err != nil {
+		return nil, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer src.Close()
+
+	out, err := os.Create(deltaFile)
+	if err != nil {
+		return nil, fmt.Errorf("创建增量文件失败: %w", err)
+	}
+	res, err := delta.Diff(sig, src, out)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("写入增量文件失败: %w", closeErr)
+	}
+	return res, err
+}
+
+// writeLocalSignature 计算 tarFile 的签名，与其 SHA-256 一起写入本地签名缓存
+// 文件格式：第一行为 SHA-256（十六进制），之后为二进制签名
+func writeLocalSignature(file, tarFile string) error {
+	src, err := os.Open(tarFile)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	sig, err := delta.ComputeSignature(io.TeeReader(src, h), delta.DefaultBlockSize)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, hex.EncodeToString(h.Sum(nil)))
+	_, err = sig.WriteTo(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// readLocalSignature 读取本地签名缓存，返回基准文件的 SHA-256 和签名
+func readLocalSignature(file string) (string, *delta.Signature, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := delta.ReadSignature(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(line), sig, nil
+}
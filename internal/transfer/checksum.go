@@ -0,0 +1,59 @@
+package transfer
+
+import (
+	"crypto/sha256"
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// checksum 在读取数据的同时计算 SHA-256，得到实际写入远程文件的数据的校验和
+type checksum struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// newChecksum 包装数据流，读取时计算校验和
+func newChecksum(r io.Reader) *checksum {
+	return &checksum{r: r, h: sha256.New()}
+}
+
+func (c *checksum) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// prime 续传时先计算远程已有部分（本地文件的前 n 字节）的校验和
+func (c *checksum) prime(local io.ReaderAt, n int64) error {
+	if _, err := io.Copy(c.h, io.NewSectionReader(local, 0, n)); err != nil {
+		return fmt.Errorf("读取本地文件失败: %w", err)
+	}
+	return nil
+}
+
+// sum 返回十六进制的校验和，数据读取完毕后调用
+func (c *checksum) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// verifyUpload 加载镜像前校验远程文件的 SHA-256，不一致时删除远程文件，由重试重新上传
+func (m *Manager) verifyUpload(sshClient *ssh.Client, target config.Target, remoteTarPath string, sum *checksum, progress *mpb.Progress) error {
+	if sum == nil {
+		return nil
+	}
+	err := sshClient.VerifyChecksum(remoteTarPath, sum.sum())
+	if errors.Is(err, ssh.ErrChecksumMismatch) {
+		if rmErr := sshClient.RemoveRemoteFile(remoteTarPath); rmErr != nil {
+			fmt.Fprintf(progress, "  ⚠️  [%s] %v\n", target.Name, rmErr)
+		}
+		return fmt.Errorf("上传的文件已损坏，已删除远程文件: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package transfer
+
+import (
+	"dockship/internal/compress"
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// TransferStats 单台主机的传输统计
+type TransferStats struct {
+	Compression string        // 压缩算法
+	RawBytes    int64         // 镜像数据的原始大小
+	WireBytes   int64         // 实际经网络传输的字节数
+	Elapsed     time.Duration // 传输耗时
+	Verified    bool          // 远程文件已通过 SHA-256 校验
+	DeltaLayers int           // 层级增量传输省略的层数
+	DeltaSaved  int64         // 层级增量传输省略的字节数
+	BinaryDelta bool          // 只发送了与远程缓存的差异（WireBytes 为增量大小）
+	RelayedFrom string        // 中继分发时转发镜像文件的主机，为空表示由本机上传
+}
+
+// String 返回压缩比、有效速率和校验结果的描述
+func (s *TransferStats) String() string {
+	rate := 0.0
+	if s.Elapsed > 0 {
+		rate = float64(s.RawBytes) / s.Elapsed.Seconds()
+	}
+	var desc string
+	switch {
+	case s.RelayedFrom != "":
+		desc = fmt.Sprintf("经 %s 转发: %s，%s/s", s.RelayedFrom, formatBytes(s.RawBytes), formatBytes(int64(rate)))
+	case s.BinaryDelta:
+		desc = fmt.Sprintf("二进制增量: %s → %s，有效速率 %s/s",
+			formatBytes(s.RawBytes), formatBytes(s.WireBytes), formatBytes(int64(rate)))
+	case s.Compression == compress.None || s.WireBytes == 0:
+		desc = fmt.Sprintf("%s，%s/s", formatBytes(s.RawBytes), formatBytes(int64(rate)))
+	default:
+		desc = fmt.Sprintf("%s: %s → %s，压缩比 %.2f，有效速率 %s/s",
+			s.Compression, formatBytes(s.RawBytes), formatBytes(s.WireBytes),
+			float64(s.RawBytes)/float64(s.WireBytes), formatBytes(int64(rate)))
+	}
+	if s.DeltaLayers > 0 {
+		desc += fmt.Sprintf("，增量传输省略 %d 层，节省 %s", s.DeltaLayers, formatBytes(s.DeltaSaved))
+	}
+	if s.Verified {
+		desc += "，SHA-256 已校验"
+	}
+	return desc
+}
+
+// formatBytes 格式化字节数
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// wireSource 按压缩配置包装镜像数据流，同时统计原始字节数和实际发送的字节数
+type wireSource struct {
+	codec string
+	raw   *countingReader
+	wire  *countingReader
+	close func()
+
+	once  sync.Once
+	start time.Time // 首次读取的时间，传输耗时从这里开始计算
+}
+
+// newWireSource 创建发送数据流，codec 为 none 时原样发送
+func newWireSource(src io.Reader, codec string, level int) (*wireSource, error) {
+	s := &wireSource{codec: codec, raw: &countingReader{r: src}, close: func() {}}
+	if codec == compress.None {
+		s.wire = s.raw
+		return s, nil
+	}
+	zr, err := compress.NewReader(s.raw, codec, level)
+	if err != nil {
+		return nil, err
+	}
+	s.wire = &countingReader{r: zr}
+	s.close = func() { zr.Close() }
+	return s, nil
+}
+
+func (s *wireSource) Read(p []byte) (int, error) {
+	s.once.Do(func() { s.start = time.Now() })
+	return s.wire.Read(p)
+}
+
+// Close 结束压缩协程
+func (s *wireSource) Close() error {
+	s.close()
+	return nil
+}
+
+// compressed 是否压缩发送
+func (s *wireSource) compressed() bool {
+	return s.codec != compress.None
+}
+
+// stats 返回从首次读取到现在的传输统计
+func (s *wireSource) stats() *TransferStats {
+	s.once.Do(func() { s.start = time.Now() })
+	return &TransferStats{
+		Compression: s.codec,
+		RawBytes:    s.raw.n.Load(),
+		WireBytes:   s.wire.n.Load(),
+		Elapsed:     time.Since(s.start),
+	}
+}
+
+// compressionFor 返回主机实际使用的压缩算法
+// zstd 需要远程安装 zstd 才能解压（docker load 只能直接读取 gzip），未安装时改用 gzip
+func (m *Manager) compressionFor(target config.Target, client *ssh.Client, progress *mpb.Progress) string {
+	codec := m.cfg.Transfer.Compression
+	if codec != compress.Zstd {
+		return codec
+	}
+
+	hc := m.pool.entry(target.Name)
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.hasZstd == nil {
+		has := client.HasCommand("zstd")
+		hc.hasZstd = &has
+		if !has {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 远程未安装 zstd，改用 gzip 压缩\n", target.Name)
+		}
+	}
+	if *hc.hasZstd {
+		return compress.Zstd
+	}
+	return compress.Gzip
+}
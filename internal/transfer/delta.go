@@ -0,0 +1,145 @@
+package transfer
+
+import (
+	"crypto/sha256"
+	"dockship/internal/config"
+	"dockship/internal/docker"
+	"dockship/internal/ssh"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// deltaArchive 发送给一台主机的精简归档，只包含远程缺少的层
+type deltaArchive struct {
+	path   string // 本地精简归档路径
+	layers int    // 省略的层文件数
+	saved  int64  // 省略的字节数
+}
+
+// imageDeltas 同一镜像的增量传输状态：归档只解析一次，省略相同层的主机共用同一个精简归档
+type imageDeltas struct {
+	once    sync.Once
+	archive *docker.Archive
+	err     error
+
+	mu      sync.Mutex
+	reduced map[string]*reducedArchive // 省略的层 -> 精简归档
+}
+
+// reducedArchive 按需生成的精简归档
+type reducedArchive struct {
+	once  sync.Once
+	delta *deltaArchive
+	err   error
+}
+
+// layerDelta 是否启用层级增量传输
+func (m *Manager) layerDelta() bool {
+	return m.cfg.Transfer.LayerDelta && !m.streaming() && m.cfg.Transfer.AutoLoad
+}
+
+// deltaFor 返回发送给主机的精简归档，远程没有可复用的层或无法增量传输时返回 nil（发送完整归档）
+// 远程已有某一层的链式ID时，docker load 直接复用该层，不需要归档中的层文件
+func (m *Manager) deltaFor(sshClient *ssh.Client, target config.Target, tarFile string, progress *mpb.Progress) *deltaArchive {
+	if !m.layerDelta() || m.pool.entry(target.Name).deltaFailed.Load() {
+		return nil
+	}
+
+	value, _ := m.deltas.LoadOrStore(tarFile, &imageDeltas{reduced: make(map[string]*reducedArchive)})
+	deltas := value.(*imageDeltas)
+	deltas.once.Do(func() {
+		deltas.archive, deltas.err = docker.OpenArchive(tarFile)
+		if deltas.err != nil {
+			fmt.Fprintf(progress, "  ⚠️  解析镜像归档失败，发送完整镜像: %v\n", deltas.err)
+		}
+	})
+	if deltas.err != nil {
+		return nil
+	}
+
+	remoteImages, err := sshClient.RemoteImageLayers()
+	if err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] %v，发送完整镜像\n", target.Name, err)
+		return nil
+	}
+	remoteChains := make(map[string]bool)
+	for _, layers := range remoteImages {
+		for _, chainID := range docker.ChainIDs(layers) {
+			remoteChains[chainID] = true
+		}
+	}
+
+	var omitted []string
+	for _, layer := range deltas.archive.Layers {
+		if remoteChains[layer.ChainID] {
+			omitted = append(omitted, layer.ChainID)
+		}
+	}
+	if len(omitted) == 0 {
+		return nil
+	}
+
+	// 省略的层相同的主机共用同一个精简归档
+	sort.Strings(omitted)
+	sum := sha256.Sum256([]byte(strings.Join(omitted, "\n")))
+	key := hex.EncodeToString(sum[:])[:12]
+
+	deltas.mu.Lock()
+	reduced, ok := deltas.reduced[key]
+	if !ok {
+		reduced = &reducedArchive{}
+		deltas.reduced[key] = reduced
+	}
+	deltas.mu.Unlock()
+
+	reduced.once.Do(func() {
+		dst := strings.TrimSuffix(tarFile, ".tar") + ".delta-" + key + ".tar"
+		layers, saved, err := deltas.archive.Reduce(dst, func(layer docker.ArchiveLayer) bool {
+			return remoteChains[layer.ChainID]
+		})
+		if err != nil {
+			reduced.err = err
+			fmt.Fprintf(progress, "  ⚠️  [%s] %v，发送完整镜像\n", target.Name, err)
+			return
+		}
+		reduced.delta = &deltaArchive{path: dst, layers: layers, saved: saved}
+	})
+	if reduced.err != nil || reduced.delta.layers == 0 {
+		return nil
+	}
+	return reduced.delta
+}
+
+// isLayerReuseError 判断精简归档的加载错误是否因为远程无法复用已有的层
+// 镜像存储找不到省略的层时，docker load 打开归档中不存在的层文件（<id>/layer.tar 或 blobs/sha256/<digest>）失败，
+// 或注册层失败；命令不存在、磁盘已满等其他错误不属于此类
+func isLayerReuseError(err error) bool {
+	msg := err.Error()
+	if strings.Contains(msg, "failed to register layer") {
+		return true
+	}
+	return strings.Contains(msg, "no such file or directory") &&
+		(strings.Contains(msg, "layer.tar") || strings.Contains(msg, "blobs/sha256/"))
+}
+
+// cleanupDeltas 删除镜像的精简归档
+func (m *Manager) cleanupDeltas(tarFile string) {
+	value, ok := m.deltas.LoadAndDelete(tarFile)
+	if !ok {
+		return
+	}
+	deltas := value.(*imageDeltas)
+	deltas.mu.Lock()
+	defer deltas.mu.Unlock()
+	for _, reduced := range deltas.reduced {
+		if reduced.delta != nil {
+			os.Remove(reduced.delta.path)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package transfer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsLayerReuseError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: open /var/lib/docker/tmp/docker-import-1234/0a1b2c/layer.tar: no such file or directory", true},
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: open /var/lib/docker/tmp/docker-import-1234/blobs/sha256/0a1b2c: no such file or directory", true},
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: failed to register layer: error creating overlay mount", true},
+		// 与层复用无关的错误原样返回，不改为传输完整镜像
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 127\n输出: bash: docker: command not found", false},
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: open /tmp/dockship/app.tar: no such file or directory", false},
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: Error response from daemon: network not found", false},
+		{"加载Docker镜像失败: 执行远程命令失败: Process exited with status 1\n输出: write /var/lib/docker/tmp/layer.tar: no space left on device", false},
+	}
+	for _, tt := range tests {
+		if got := isLayerReuseError(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("isLayerReuseError(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package transfer
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// multicastChunkSize 批量分发时每次从数据源读取的块大小
+const multicastChunkSize = 256 * 1024
+
+// errDetached 主机接收过慢，已脱离批量分发
+var errDetached = errors.New("接收速度过慢，已脱离批量分发")
+
+// multicast 只读取一次数据源，同时分发给多个读取方
+// 每个读取方有固定大小的缓冲区；数据源按最慢的读取方的速度推进（背压），
+// 某个读取方的缓冲区持续写满超过 slowTimeout 时将其脱离，避免拖慢其他主机
+type multicast struct {
+	src         io.Reader
+	readers     []*multicastReader
+	slowTimeout time.Duration
+}
+
+// multicastReader 批量分发中单台主机的读取端
+type multicastReader struct {
+	ch   chan []byte // 待读取的数据块，数据源结束时关闭
+	cur  []byte      // 当前数据块中未读取的部分
+	err  error       // 数据源的结束原因（io.EOF 或读取错误），在 ch 关闭前设置
+	done chan struct{}
+	once sync.Once
+
+	n          int64     // 已读取的字节数，只由读取方访问
+	start, end time.Time // 首次读取和读到数据末尾的时间，只由读取方访问
+
+	mu      sync.Mutex
+	doneErr error // 读取端被关闭或脱离的原因
+}
+
+// newMulticast 创建批量分发，bufferSize 为每个读取方的缓冲区大小（字节）
+func newMulticast(src io.Reader, readers int, bufferSize int, slowTimeout time.Duration) *multicast {
+	chunks := max(bufferSize/multicastChunkSize, 1)
+	m := &multicast{src: src, slowTimeout: slowTimeout}
+	for range readers {
+		m.readers = append(m.readers, &multicastReader{
+			ch:   make(chan []byte, chunks),
+			done: make(chan struct{}),
+		})
+	}
+	return m
+}
+
+// reader 返回第 i 个读取端
+func (m *multicast) reader(i int) *multicastReader {
+	return m.readers[i]
+}
+
+// run 读取数据源并分发，直到数据源结束或所有读取端都已关闭
+func (m *multicast) run() {
+	for {
+		if m.allDone() {
+			return
+		}
+
+		chunk := make([]byte, multicastChunkSize)
+		n, err := io.ReadFull(m.src, chunk)
+		if n > 0 {
+			for _, r := range m.readers {
+				m.deliver(r, chunk[:n])
+			}
+		}
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if err != nil {
+			for _, r := range m.readers {
+				r.err = err
+				close(r.ch)
+			}
+			return
+		}
+	}
+}
+
+// deliver 将数据块写入读取端的缓冲区，缓冲区持续写满超过 slowTimeout 时脱离该读取端
+func (m *multicast) deliver(r *multicastReader, chunk []byte) {
+	select {
+	case r.ch <- chunk:
+		return
+	case <-r.done:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(m.slowTimeout)
+	defer timer.Stop()
+
+	select {
+	case r.ch <- chunk:
+	case <-r.done:
+	case <-timer.C:
+		r.closeWith(errDetached)
+	}
+}
+
+// allDone 所有读取端是否都已关闭
+func (m *multicast) allDone() bool {
+	for _, r := range m.readers {
+		select {
+		case <-r.done:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Read 读取分发的数据
+func (r *multicastReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	for len(r.cur) == 0 {
+		select {
+		case <-r.done:
+			return 0, r.closedErr()
+		case chunk, ok := <-r.ch:
+			if !ok {
+				if r.end.IsZero() {
+					r.end = time.Now()
+				}
+				return 0, r.err
+			}
+			r.cur = chunk
+		}
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	r.n += int64(n)
+	return n, nil
+}
+
+// elapsed 返回从首次读取到读完数据（未读完时为现在）的时间
+func (r *multicastReader) elapsed() time.Duration {
+	if r.start.IsZero() {
+		return 0
+	}
+	end := r.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(r.start)
+}
+
+// Close 关闭读取端，之后数据源不再等待该读取端
+func (r *multicastReader) Close() error {
+	r.closeWith(io.ErrClosedPipe)
+	return nil
+}
+
+// detached 读取端是否因接收过慢被脱离
+func (r *multicastReader) detached() bool {
+	return errors.Is(r.closedErr(), errDetached)
+}
+
+func (r *multicastReader) closeWith(err error) {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.doneErr = err
+		r.mu.Unlock()
+		close(r.done)
+	})
+}
+
+func (r *multicastReader) closedErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doneErr
+}
@@ -0,0 +1,121 @@
+package transfer
+
+import (
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// connPool 按主机复用SSH/SFTP连接，所有镜像共享同一主机的连接
+// 连接失效时在下次获取时自动重连
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*hostConn // 主机名称 -> 连接
+
+	dials int // 实际建立的连接次数（用于统计）
+}
+
+// hostConn 单台主机的共享连接
+type hostConn struct {
+	mu            sync.Mutex
+	client        *ssh.Client
+	dockerChecked bool  // 远程 Docker 已检查可用，每台主机只检查一次
+	hasZstd       *bool // 远程是否安装 zstd，首次使用 zstd 压缩时检查
+
+	deltaFailed atomic.Bool // 精简归档加载失败（如使用 containerd 镜像存储），之后总是发送完整归档
+
+	helperMu     sync.Mutex
+	helper       string // 远程增量辅助程序路径，为空表示尚未安装
+	helperFailed bool   // 远程无法使用辅助程序（平台不同、无法执行等），不再尝试安装
+}
+
+// newConnPool 创建连接池
+func newConnPool() *connPool {
+	return &connPool{conns: make(map[string]*hostConn)}
+}
+
+// entry 获取主机的连接项，不存在时创建
+func (p *connPool) entry(host string) *hostConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hc, ok := p.conns[host]
+	if !ok {
+		hc = &hostConn{}
+		p.conns[host] = hc
+	}
+	return hc
+}
+
+// stats 返回已连接的主机数和建立连接的总次数
+func (p *connPool) stats() (hosts, dials int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns), p.dials
+}
+
+// closeAll 关闭所有连接
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for host, hc := range p.conns {
+		hc.mu.Lock()
+		if hc.client != nil {
+			hc.client.Close()
+			hc.client = nil
+		}
+		hc.mu.Unlock()
+		delete(p.conns, host)
+	}
+}
+
+// client 返回目标主机的可用连接，首次使用或连接失效时建立新连接
+// 同一主机的建连过程串行执行，多个镜像同时需要连接时只握手一次
+func (m *Manager) client(target config.Target, progress *mpb.Progress) (*ssh.Client, error) {
+	return m.connect(target, true, progress)
+}
+
+// connect 返回主机的可用连接，checkDocker 为 false 时不检查远程 Docker（只负责转发的中转主机）
+func (m *Manager) connect(target config.Target, checkDocker bool, progress *mpb.Progress) (*ssh.Client, error) {
+	hc := m.pool.entry(target.Name)
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.client != nil {
+		if hc.client.Alive() {
+			return hc.client, nil
+		}
+		hc.client.Close()
+		hc.client = nil
+	}
+
+	opts, err := m.clientOptions(target)
+	if err != nil {
+		return nil, err
+	}
+	client := ssh.NewClient(opts)
+	if err := client.Connect(progress); err != nil {
+		return nil, err
+	}
+
+	m.pool.mu.Lock()
+	m.pool.dials++
+	m.pool.mu.Unlock()
+
+	// 检查远程Docker是否可用（每台主机只检查一次）
+	if checkDocker && !hc.dockerChecked {
+		if err := client.CheckDockerAvailable(); err != nil {
+			client.Close()
+			return nil, err
+		}
+		hc.dockerChecked = true
+	}
+
+	hc.client = client
+	return client, nil
+}
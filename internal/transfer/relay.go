@@ -0,0 +1,289 @@
+package transfer
+
+import (
+	"dockship/internal/config"
+	"dockship/internal/ssh"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+// relayNode 分发树中的一台主机
+type relayNode struct {
+	target config.Target
+	client *ssh.Client
+	index  int // 在 targets 中的位置，-1 表示只负责转发（中转主机，或不需要从这里加载镜像的种子主机）
+	parent *relayNode
+
+	remotePath string        // 收到的镜像文件
+	src        string        // 转发给其他主机时读取的文件
+	ready      chan struct{} // 镜像文件就绪或接收失败时关闭
+	ok         bool          // 镜像文件已就绪，ready 关闭后读取
+}
+
+// relayed 中继分发的共享状态
+type relayed struct {
+	codec   string
+	size    int64     // 镜像文件大小（按 codec 压缩后）
+	raw     int64     // 镜像数据的原始大小
+	sum     *checksum // 种子主机上传数据的校验和，未开启校验时为 nil
+	mu      sync.Mutex
+	cleanup []*relayNode // 所有主机结束后需要删除转发文件（src）的主机
+}
+
+// relayToHosts 中继分发：本机只向种子主机上传一次，已收到镜像的主机再通过 ssh 转发给分发树中的下级主机
+// 每台主机最多同时转发给 fanout 台主机；上级主机接收失败时向更上一级的主机获取，都失败时由本机直接传输
+// 成功或跳过的主机写入 results 并将 attempts 置为 0，其余主机（包括加载镜像失败的主机）保留尝试次数，随后单独传输
+func (m *Manager) relayToHosts(targets []config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress, results []TransferResult, attempts []int) {
+	codec := m.cfg.Transfer.Compression
+	clients := m.relayClients(targets, imageCfg, tarFile, codec, progress, results, attempts)
+
+	// 1. 确定种子主机：指定的种子主机（目标主机或中转主机），未指定或连接失败时使用第一台可以中继的主机
+	var nodes []*relayNode
+	if seed := m.cfg.RelaySeed; seed != nil {
+		index := -1
+		for i, target := range targets {
+			if target.Name == seed.Name && clients[i] != nil {
+				index = i
+			}
+		}
+		client, err := m.connect(*seed, index >= 0, progress)
+		if err != nil {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 种子主机连接失败: %v，改用第一台目标主机作为种子\n", seed.Name, err)
+		} else {
+			nodes = append(nodes, &relayNode{target: *seed, client: client, index: index})
+		}
+	}
+	for i, client := range clients {
+		if client != nil && (len(nodes) == 0 || nodes[0].index != i) {
+			nodes = append(nodes, &relayNode{target: targets[i], client: client, index: i})
+		}
+	}
+	// 只有一台主机时不需要转发
+	if len(nodes) < 2 {
+		return
+	}
+
+	// 2. 按 fanout 组成分发树：第 i 台主机的下级为第 i*fanout+1 到 i*fanout+fanout 台
+	fanout := m.cfg.Transfer.Relay.Fanout
+	for i, node := range nodes {
+		node.remotePath = uploadPath(node.target, tarFile, codec)
+		node.ready = make(chan struct{})
+		if i > 0 {
+			node.parent = nodes[(i-1)/fanout]
+		}
+	}
+	fmt.Fprintf(progress, "  🌱 [%s] 中继分发: 种子主机，%d 台主机经分发树转发（每台最多转发 %d 台）\n",
+		nodes[0].target.Name, len(nodes)-1, fanout)
+
+	// 3. 每台主机收到镜像文件后开始向下级转发，同时加载镜像
+	state := &relayed{codec: codec}
+	hasChildren := func(i int) bool { return i*fanout+1 < len(nodes) }
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *relayNode) {
+			defer wg.Done()
+			m.relayNode(node, hasChildren(i), state, imageCfg, tarFile, progress, results, attempts)
+		}(i, node)
+	}
+	wg.Wait()
+
+	// 4. 所有主机都结束后删除转发用的文件（下级主机可能回退到更上一级的主机获取）
+	for _, node := range state.cleanup {
+		node.client.RemoveRemoteFile(node.src)
+	}
+}
+
+// relayClients 并发连接所有目标主机，返回可以参与中继分发的主机的连接，其余为 nil
+// 远程已有相同镜像的主机直接记为跳过；连接失败、需要其他压缩算法或可以增量传输的主机随后单独传输
+func (m *Manager) relayClients(targets []config.Target, imageCfg config.ImageConfig, tarFile, codec string, progress *mpb.Progress, results []TransferResult, attempts []int) []*ssh.Client {
+	clients := make([]*ssh.Client, len(targets))
+	semaphore := make(chan struct{}, m.cfg.Transfer.Concurrent)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target config.Target) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			client, err := m.client(target, progress)
+			if err != nil {
+				return
+			}
+			if m.upToDate(client, imageCfg) {
+				results[i] = TransferResult{Host: target.Name, Image: imageCfg.Name, Success: true, Skipped: true}
+				attempts[i] = 0
+				return
+			}
+			if m.compressionFor(target, client, progress) != codec || m.deltaFor(client, target, tarFile, progress) != nil || m.hasBasis(client, imageCfg) {
+				return
+			}
+			clients[i] = client
+		}(i, target)
+	}
+	wg.Wait()
+	return clients
+}
+
+// relayNode 获取单台主机的镜像文件（种子主机由本机上传，其他主机由上级主机转发），就绪后加载镜像
+func (m *Manager) relayNode(node *relayNode, hasChildren bool, state *relayed, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress, results []TransferResult, attempts []int) {
+	var stats *TransferStats
+	var err error
+	if node.parent == nil {
+		stats, err = m.seedUpload(node, state, imageCfg, tarFile, progress)
+	} else {
+		stats, err = m.relayReceive(node, state, progress)
+	}
+
+	if err != nil {
+		close(node.ready)
+		if node.index < 0 {
+			fmt.Fprintf(progress, "  ⚠️  [%s] %v\n", node.target.Name, err)
+			return
+		}
+		if node.parent == nil {
+			// 种子主机已用完本机上传的尝试次数
+			results[node.index] = TransferResult{Host: node.target.Name, Image: imageCfg.Name, Error: err}
+			attempts[node.index] = 0
+			return
+		}
+		fmt.Fprintf(progress, "  ⚠️  [%s] %v，稍后由本机直接传输\n", node.target.Name, err)
+		return
+	}
+
+	// 下级主机从 src 读取。加载镜像后会按 auto_cleanup 删除收到的文件，因此另外保留一个硬链接用于转发；
+	// 只转发的主机不加载镜像，src 就是收到的文件。这些文件在所有主机结束后删除
+	node.src, node.ok = node.remotePath, true
+	cleanup := node.index < 0 && m.cfg.RemoteStorage.AutoCleanup
+	if hasChildren && node.index >= 0 && m.cfg.RemoteStorage.AutoCleanup {
+		node.src = node.remotePath + ".relay"
+		if err := node.client.LinkFile(node.remotePath, node.src); err != nil {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 保留转发文件失败: %v，下级主机改向上一级主机获取\n", node.target.Name, err)
+			node.ok = false
+		} else {
+			cleanup = true
+		}
+	}
+	if cleanup {
+		state.mu.Lock()
+		state.cleanup = append(state.cleanup, node)
+		state.mu.Unlock()
+	}
+	close(node.ready)
+
+	if node.index < 0 {
+		if node.parent == nil {
+			fmt.Fprintf(progress, "  🌱 [%s] 已上传到种子主机（%s）\n", node.target.Name, stats)
+		}
+		return
+	}
+	err = m.loadUploaded(node.client, node.target, imageCfg, node.remotePath, state.codec, m.cacheSource(tarFile, state.codec), progress)
+	if err != nil {
+		// 保留尝试次数，按正常流程单独重试
+		fmt.Fprintf(progress, "  ⚠️  [%s] 加载转发的镜像失败: %v，稍后单独重试\n", node.target.Name, err)
+		return
+	}
+	results[node.index] = TransferResult{Host: node.target.Name, Image: imageCfg.Name, Success: true, Stats: stats}
+	attempts[node.index] = 0
+}
+
+// seedUpload 由本机向种子主机上传镜像文件（最多尝试 retry 次，重试时续传）
+func (m *Manager) seedUpload(node *relayNode, state *relayed, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) (*TransferStats, error) {
+	var lastErr error
+	for attempt := 1; attempt <= m.cfg.Transfer.Retry; attempt++ {
+		stats, sum, err := m.upload(node.client, node.target, imageCfg, tarFile, state.codec, attempt > 1, progress)
+		if err == nil {
+			size, err := node.client.RemoteFileSize(node.remotePath)
+			if err != nil {
+				return nil, fmt.Errorf("获取种子主机上的镜像文件大小失败: %w", err)
+			}
+			state.size, state.raw, state.sum = size, stats.RawBytes, sum
+			return stats, nil
+		}
+
+		lastErr = err
+		if attempt < m.cfg.Transfer.Retry {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 第 %d 次传输失败: %v，2 秒后重试\n", node.target.Name, attempt, err)
+			time.Sleep(2 * time.Second)
+			// 连接可能已断开（如上传停滞），重试前重新获取
+			if client, err := m.connect(node.target, node.index >= 0, progress); err == nil {
+				node.client = client
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// relayReceive 等待上级主机就绪后由其转发镜像文件，上级主机接收失败时向更上一级的主机获取
+func (m *Manager) relayReceive(node *relayNode, state *relayed, progress *mpb.Progress) (*TransferStats, error) {
+	source := node.parent
+	for source != nil {
+		<-source.ready
+		if source.ok {
+			break
+		}
+		source = source.parent
+	}
+	if source == nil {
+		return nil, errors.New("上级主机都没有收到镜像文件")
+	}
+
+	peer := ssh.Peer{
+		Address: node.target.Address,
+		Port:    node.target.Port,
+		User:    node.target.User,
+		KeyFile: m.cfg.Transfer.Relay.KeyFile,
+		Options: m.cfg.Transfer.Relay.SSHOptions,
+		Timeout: m.cfg.SSH.Timeout,
+	}
+	// 转发主机连接接收方时沿用本机的主机密钥校验策略
+	address := net.JoinHostPort(node.target.Address, strconv.Itoa(node.target.Port))
+	peer.HostKeyChecking, peer.KnownHosts = m.hostKeys.ForwardOptions(address)
+	var lastErr error
+	for attempt := 1; attempt <= m.cfg.Transfer.Retry; attempt++ {
+		start := time.Now()
+		err := source.client.PushFile(source.src, state.size, peer, node.remotePath, node.client, progress)
+		if err == nil {
+			err = m.verifyRelayed(node, state, progress)
+		}
+		if err == nil {
+			return &TransferStats{
+				Compression: state.codec,
+				RawBytes:    state.raw,
+				WireBytes:   state.size,
+				Elapsed:     time.Since(start),
+				Verified:    state.sum != nil,
+				RelayedFrom: source.target.Name,
+			}, nil
+		}
+
+		lastErr = err
+		if attempt < m.cfg.Transfer.Retry {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 第 %d 次转发失败: %v，2 秒后重试\n", node.target.Name, attempt, err)
+			time.Sleep(2 * time.Second)
+		}
+	}
+	return nil, fmt.Errorf("中继转发失败: %w", lastErr)
+}
+
+// verifyRelayed 校验转发到主机的镜像文件：开启校验时比较 SHA-256，否则只比较大小
+func (m *Manager) verifyRelayed(node *relayNode, state *relayed, progress *mpb.Progress) error {
+	if state.sum != nil {
+		return m.verifyUpload(node.client, node.target, node.remotePath, state.sum, progress)
+	}
+	size, err := node.client.RemoteFileSize(node.remotePath)
+	if err != nil {
+		return fmt.Errorf("获取远程文件大小失败: %w", err)
+	}
+	if size != state.size {
+		return fmt.Errorf("转发的文件不完整: 期望 %d 字节，实际 %d 字节", state.size, size)
+	}
+	return nil
+}
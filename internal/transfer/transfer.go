@@ -1,13 +1,20 @@
 package transfer
 
 import (
+	"dockship/internal/bandwidth"
+	"dockship/internal/compress"
 	"dockship/internal/config"
 	"dockship/internal/docker"
 	"dockship/internal/ssh"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vbauerster/mpb/v8"
@@ -17,6 +24,21 @@ import (
 type Manager struct {
 	cfg          *config.Config
 	dockerClient *docker.Client
+	hostKeys     *ssh.HostKeyVerifier         // 主机密钥校验器，所有主机连接共享
+	dialer       *ssh.Dialer                  // SSH连接器，跳板机连接在整个运行期间复用
+	pool         *connPool                    // 目标主机连接池，所有镜像共享
+	bandwidth    *bandwidth.Limiter           // 上传限速器，所有主机的上传共享，未配置限速时不限速
+	progress     atomic.Pointer[mpb.Progress] // 正在传输的镜像的进度条容器，用于输出运行中的提示
+
+	authMu  sync.Mutex
+	keyring *ssh.Keyring                  // 所有认证器共享的私钥和 ssh-agent 连接
+	auths   map[string]*ssh.Authenticator // 每种凭据组合（目标主机、跳板机）的认证器
+
+	imageIDs  sync.Map // 镜像名称 -> 本地镜像 ID
+	preLoaded sync.Map // 已执行过 pre_load hooks、随后改为其他方式继续本次尝试的主机和镜像（preLoadKey -> struct{}）
+	deltas    sync.Map // tar 文件 -> *imageDeltas，层级增量传输的精简归档
+
+	cacheLocks sync.Map // 主机和仓库 -> *sync.Mutex，二进制增量基准文件的锁
 }
 
 // NewManager 创建传输管理器
@@ -29,10 +51,12 @@ func NewManager(cfg *config.Config) *Manager {
 
 // TransferResult 传输结果
 type TransferResult struct {
-	Host    string // 目标主机
-	Image   string // 镜像名称
-	Success bool   // 是否成功
-	Error   error  // 错误信息
+	Host    string         // 目标主机
+	Image   string         // 镜像名称
+	Success bool           // 是否成功
+	Skipped bool           // 远程已有相同的镜像，跳过传输
+	Error   error          // 错误信息
+	Stats   *TransferStats // 传输统计（成功时）
 }
 
 type preparedImage struct {
@@ -56,6 +80,40 @@ func (m *Manager) Start() error {
 		return err
 	}
 
+	// 初始化主机密钥校验器
+	hostKeys, err := ssh.NewHostKeyVerifier(
+		m.cfg.SSH.HostKeyPolicy,
+		m.cfg.SSH.UserKnownHosts,
+		m.cfg.SSH.KnownHostsFile,
+		m.cfg.SSH.PinnedFingerprints(),
+	)
+	if err != nil {
+		return err
+	}
+	m.hostKeys = hostKeys
+
+	// 初始化SSH认证器（连接 ssh-agent、解析私钥）
+	// 在启动并发传输前完成，加密私钥的口令只需输入一次
+	if err := m.initAuth(); err != nil {
+		return err
+	}
+	defer m.closeAuth()
+
+	// 初始化SSH连接器，跳板机连接在所有镜像和主机间复用
+	m.dialer = ssh.NewDialer(m.hostKeys, m.cfg.SSH.Timeout, ssh.Keepalive{
+		Interval: m.cfg.SSH.KeepaliveInterval,
+		CountMax: m.cfg.SSH.KeepaliveCountMax,
+	})
+	defer m.dialer.Close()
+
+	// 初始化主机连接池，每台主机在整个运行期间只保持一条连接
+	m.pool = newConnPool()
+	defer m.pool.closeAll()
+
+	// 初始化上传限速器，运行中收到 SIGHUP 时按配置文件更新限速
+	m.bandwidth = newLimiter(m.cfg)
+	defer m.watchReload()()
+
 	startTime := time.Now()
 
 	imageCount := len(m.cfg.Images)
@@ -78,7 +136,14 @@ func (m *Manager) Start() error {
 		go func() {
 			defer prepareWg.Done()
 			for imageCfg := range imageCh {
-				tarFile, err := m.dockerClient.PrepareImage(imageCfg.Name)
+				var tarFile string
+				var err error
+				if m.streaming() {
+					// 流式传输不保存 tar 文件，只确保本地镜像存在
+					err = m.dockerClient.EnsureImageExists(imageCfg.Name)
+				} else {
+					tarFile, err = m.dockerClient.PrepareImage(imageCfg.Name)
+				}
 				preparedCh <- preparedImage{
 					ImageCfg: imageCfg,
 					TarFile:  tarFile,
@@ -128,6 +193,9 @@ func (m *Manager) Start() error {
 
 	elapsed := time.Since(startTime)
 	fmt.Println("\n" + strings.Repeat("=", 60))
+	if hosts, dials := m.pool.stats(); hosts > 0 {
+		fmt.Printf("🔌 SSH连接: %d 台主机，共建立 %d 次连接\n", hosts, dials)
+	}
 	fmt.Printf("✅ 所有任务完成，总耗时: %.2f 秒\n", elapsed.Seconds())
 
 	return nil
@@ -138,9 +206,14 @@ func (m *Manager) handlePreparedImage(prepared preparedImage) error {
 		return prepared.Err
 	}
 
+	if m.streaming() {
+		return m.transferPreparedImage(prepared.ImageCfg, "")
+	}
+
 	if prepared.TarFile == "" {
 		return fmt.Errorf("镜像 %s 的 tar 文件不存在", prepared.ImageCfg.Name)
 	}
+	defer m.cleanupDeltas(prepared.TarFile)
 
 	if m.cfg.LocalStorage.AutoCleanup {
 		defer func(tarPath string) {
@@ -161,13 +234,19 @@ func (m *Manager) transferPreparedImage(imageCfg config.ImageConfig, tarFile str
 		mpb.WithRefreshRate(120 * time.Millisecond),
 	)
 
+	m.progress.Store(progress)
 	results := m.transferToHosts(imageCfg, tarFile, progress)
+	m.progress.Store(nil)
 
 	progress.Wait()
 
 	fmt.Println()
 	for _, result := range results {
-		if result.Success {
+		if result.Skipped {
+			fmt.Printf("  ⏭️  [%s] 已是最新（镜像ID一致），跳过\n", result.Host)
+		} else if result.Success && result.Stats != nil {
+			fmt.Printf("  ✅ [%s] 镜像传输完成（%s）\n", result.Host, result.Stats)
+		} else if result.Success {
 			fmt.Printf("  ✅ [%s] 镜像传输完成\n", result.Host)
 		} else {
 			fmt.Printf("  ❌ [%s] 失败: %v\n", result.Host, result.Error)
@@ -175,70 +254,109 @@ func (m *Manager) transferPreparedImage(imageCfg config.ImageConfig, tarFile str
 	}
 
 	success := 0
+	skipped := 0
 	failed := 0
 	for _, result := range results {
-		if result.Success {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.Success:
 			success++
-		} else {
+		default:
 			failed++
 		}
 	}
 
-	fmt.Printf("\n📊 镜像 %s 传输统计: 成功 %d 台，失败 %d 台\n", imageCfg.Name, success, failed)
+	if skipped > 0 {
+		fmt.Printf("\n📊 镜像 %s 传输统计: 成功 %d 台，跳过 %d 台，失败 %d 台\n", imageCfg.Name, success, skipped, failed)
+	} else {
+		fmt.Printf("\n📊 镜像 %s 传输统计: 成功 %d 台，失败 %d 台\n", imageCfg.Name, success, failed)
+	}
 	return nil
 }
 
-// transferToHosts 并发传输到多个主机
+// transferToHosts 并发传输到镜像匹配的目标主机
+// 开启批量分发时，每批主机共享一次本地读取，失败或被脱离的主机再单独传输；
+// 开启中继分发时，本机只向种子主机上传，其他主机由已收到镜像的主机转发，无法转发的主机再单独传输
 func (m *Manager) transferToHosts(imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) []TransferResult {
 	var wg sync.WaitGroup
-	results := make([]TransferResult, len(m.cfg.TargetHosts))
+	targets := m.cfg.TargetsFor(imageCfg)
+	results := make([]TransferResult, len(targets))
+
+	// 每台主机单独传输时的尝试次数，为 0 表示已经完成
+	attempts := make([]int, len(targets))
+	for i := range targets {
+		attempts[i] = m.cfg.Transfer.Retry
+	}
+	// 开启中继分发时不使用批量分发
+	relay := m.cfg.Transfer.Relay.Enabled
+	multicast := !relay && m.cfg.Transfer.Multicast && len(targets) > 1
+	if relay {
+		m.relayToHosts(targets, imageCfg, tarFile, progress, results, attempts)
+	} else if multicast {
+		m.multicastToHosts(targets, imageCfg, tarFile, progress, results, attempts)
+	}
 
 	// 创建信号量控制并发数
 	semaphore := make(chan struct{}, m.cfg.Transfer.Concurrent)
 
-	for i, host := range m.cfg.TargetHosts {
+	for i, target := range targets {
+		if attempts[i] <= 0 {
+			continue
+		}
 		wg.Add(1)
 
-		go func(index int, targetHost string) {
+		go func(index int, target config.Target) {
 			defer wg.Done()
 
 			// 获取信号量
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			// 执行传输
-			result := m.transferToHost(targetHost, imageCfg, tarFile, progress)
+			// 执行传输（批量分发中失败的主机可以续传已上传的部分）
+			result := m.transferToHost(target, imageCfg, tarFile, progress, attempts[index], multicast)
 			results[index] = result
-		}(i, host)
+		}(i, target)
 	}
 
 	wg.Wait()
 	return results
 }
 
-// transferToHost 传输镜像到单个主机（带重试）
-func (m *Manager) transferToHost(host string, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) TransferResult {
+// transferToHost 传输镜像到单个主机（最多尝试 maxRetries 次）
+// 重试时（或 resume 为 true 时）远程已有部分 tar 文件则从断点续传
+func (m *Manager) transferToHost(target config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress, maxRetries int, resume bool) TransferResult {
 	var lastErr error
-	maxRetries := m.cfg.Transfer.Retry
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := m.doTransfer(host, imageCfg, tarFile, progress)
+		stats, err := m.doTransfer(target, imageCfg, tarFile, progress, resume || attempt > 1)
+		m.preLoaded.Delete(preLoadKey(target, imageCfg)) // 本次尝试没有用到的标记不带入下一次尝试
+		if errors.Is(err, errUpToDate) {
+			return TransferResult{
+				Host:    target.Name,
+				Image:   imageCfg.Name,
+				Success: true,
+				Skipped: true,
+			}
+		}
 		if err == nil {
 			return TransferResult{
-				Host:    host,
+				Host:    target.Name,
 				Image:   imageCfg.Name,
 				Success: true,
+				Stats:   stats,
 			}
 		}
 
 		lastErr = err
 		if attempt < maxRetries {
+			fmt.Fprintf(progress, "  ⚠️  [%s] 第 %d 次传输失败: %v，2 秒后重试\n", target.Name, attempt, err)
 			time.Sleep(2 * time.Second) // 重试前等待
 		}
 	}
 
 	return TransferResult{
-		Host:    host,
+		Host:    target.Name,
 		Image:   imageCfg.Name,
 		Success: false,
 		Error:   lastErr,
@@ -246,64 +364,202 @@ func (m *Manager) transferToHost(host string, imageCfg config.ImageConfig, tarFi
 }
 
 // doTransfer 执行实际的传输操作
-func (m *Manager) doTransfer(host string, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress) error {
-	// 1. 创建SSH客户端
-	sshClient := ssh.NewClient(
-		host,
-		m.cfg.SSH.Port,
-		m.cfg.SSH.User,
-		m.cfg.SSH.Password,
-		m.cfg.SSH.KeyFile,
-		m.cfg.SSH.Timeout,
-		progress,
-	)
+func (m *Manager) doTransfer(target config.Target, imageCfg config.ImageConfig, tarFile string, progress *mpb.Progress, resume bool) (*TransferStats, error) {
+	// 1. 获取主机连接（连接池中复用，首次连接时检查远程Docker是否可用）
+	sshClient, err := m.client(target, progress)
+	if err != nil {
+		return nil, err
+	}
 
-	// 2. 连接SSH
-	if err := sshClient.Connect(); err != nil {
-		return err
+	// 远程已有相同的镜像时跳过（--force 时总是传输）
+	if m.upToDate(sshClient, imageCfg) {
+		return nil, errUpToDate
 	}
-	defer sshClient.Close()
+	codec := m.compressionFor(target, sshClient, progress)
 
-	// 3. 检查远程Docker是否可用
-	if err := sshClient.CheckDockerAvailable(); err != nil {
-		return err
+	// 流式传输：镜像直接导入，没有上传和清理步骤
+	if m.streaming() {
+		return m.doStreamTransfer(sshClient, target, imageCfg, codec, progress)
 	}
 
-	// 4. 上传tar文件到远程临时目录
-	remoteTarPath := filepath.Join(m.cfg.RemoteStorage.TempDir, filepath.Base(tarFile))
-	if err := sshClient.UploadFile(tarFile, remoteTarPath); err != nil {
-		return err
+	// 2. 上传tar文件到远程临时目录（开启压缩时边读取边压缩，开启层级增量时只发送远程缺少的层）
+	uploadFile := tarFile
+	delta := m.deltaFor(sshClient, target, tarFile, progress)
+	if delta != nil {
+		uploadFile = delta.path
 	}
 
-	// 5. 执行hooks（全局 + 镜像级）
-	vars := map[string]string{"image": imageCfg.Name}
+	// 开启二进制增量且远程缓存中有上一次传输的 tar 文件时，只上传差异，在远程重建 tar 文件
+	if delta == nil && m.binaryDelta() {
+		stats, err := m.sendBinaryDelta(sshClient, target, imageCfg, tarFile, progress)
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			remoteTarPath := uploadPath(target, tarFile, compress.None)
+			return stats, m.loadUploaded(sshClient, target, imageCfg, remoteTarPath, compress.None, tarFile, progress)
+		}
+	}
+
+	remoteTarPath := uploadPath(target, uploadFile, codec)
+	stats, _, err := m.upload(sshClient, target, imageCfg, uploadFile, codec, resume, progress)
+	if err != nil {
+		return nil, err
+	}
 
-	// 5a. 执行全局 pre_load hooks
-	if len(m.cfg.Hooks.PreLoad) > 0 {
-		sshClient.ExecuteHooks("pre_load", m.cfg.Hooks.PreLoad, vars)
+	var cacheTar string
+	if delta == nil {
+		cacheTar = m.cacheSource(tarFile, codec)
 	}
-	// 5b. 执行镜像级 pre_load hooks
-	if len(imageCfg.Hooks.PreLoad) > 0 {
-		sshClient.ExecuteHooks("pre_load", imageCfg.Hooks.PreLoad, vars)
+	err = m.loadUploaded(sshClient, target, imageCfg, remoteTarPath, codec, cacheTar, progress)
+	if err != nil && delta != nil && isLayerReuseError(err) {
+		// 远程无法复用已有的层（如使用 containerd 镜像存储），之后总是发送完整归档；
+		// 其他加载错误按普通失败重试，下次仍然尝试增量传输
+		fmt.Fprintf(progress, "  ⚠️  [%s] 远程无法复用已有的层，改为传输完整镜像: %v\n", target.Name, err)
+		m.pool.entry(target.Name).deltaFailed.Store(true)
+		m.preLoaded.Store(preLoadKey(target, imageCfg), struct{}{})
+		sshClient.RemoveRemoteFile(remoteTarPath)
+		return m.doTransfer(target, imageCfg, tarFile, progress, resume)
+	}
+	if delta != nil {
+		stats.DeltaLayers, stats.DeltaSaved = delta.layers, delta.saved
 	}
+	return stats, err
+}
 
-	// 6. 根据配置决定是否加载Docker镜像
+// upload 上传文件到远程临时目录（开启压缩时边读取边压缩），resume 为 true 时从远程已有的部分续传
+// 开启校验时上传后校验远程文件，返回传输统计和上传数据的校验和（未开启校验时为 nil）
+func (m *Manager) upload(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, uploadFile, codec string, resume bool, progress *mpb.Progress) (*TransferStats, *checksum, error) {
+	src, size, closeSrc, err := m.openSource(imageCfg, uploadFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeSrc()
+
+	remoteTarPath := uploadPath(target, uploadFile, codec)
+
+	// 续传：只有未压缩的 tar 文件可以按偏移定位，压缩数据总是从头上传
+	var offset int64
+	file, _ := src.(*os.File)
+	if file != nil && resume && codec == compress.None {
+		offset = m.resumeOffset(sshClient, target, file, size, remoteTarPath, progress)
+	}
+
+	ws, err := newWireSource(src, codec, m.cfg.Transfer.CompressionLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ws.Close()
+	if ws.compressed() {
+		size = -1 // 压缩后的大小未知
+	}
+
+	// 上传的同时计算写入远程文件的数据的校验和，续传时包含远程已有的部分
+	var upload io.Reader = ws
+	var sum *checksum
+	if m.verifying() {
+		sum = newChecksum(ws)
+		upload = sum
+		if offset > 0 {
+			if err := sum.prime(file, offset); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if err := sshClient.ResumeReader(upload, size, offset, remoteTarPath, progress); err != nil {
+		return nil, nil, err
+	}
+	stats := ws.stats()
+
+	// 加载前校验远程文件，数据损坏时删除远程文件并重试
+	if err := m.verifyUpload(sshClient, target, remoteTarPath, sum, progress); err != nil {
+		return nil, nil, err
+	}
+	stats.Verified = sum != nil
+	return stats, sum, nil
+}
+
+// errUpToDate 远程主机已有与本地相同的镜像
+var errUpToDate = errors.New("远程已有相同的镜像")
+
+// upToDate 远程主机上的镜像 ID 是否与本地一致
+// 未开启 auto_load 时需要的是远程 tar 文件，指定 --force 时强制传输，这两种情况都不跳过
+func (m *Manager) upToDate(sshClient *ssh.Client, imageCfg config.ImageConfig) bool {
+	if m.cfg.Transfer.Force || !m.cfg.Transfer.AutoLoad {
+		return false
+	}
+	localID := m.localImageID(imageCfg.Name)
+	return localID != "" && sshClient.RemoteImageID(imageCfg.Name) == localID
+}
+
+// localImageID 返回本地镜像 ID，每个镜像只查询一次，查询失败时返回空字符串（不跳过任何主机）
+func (m *Manager) localImageID(image string) string {
+	if id, ok := m.imageIDs.Load(image); ok {
+		return id.(string)
+	}
+	id, err := m.dockerClient.ImageID(image)
+	if err != nil {
+		id = ""
+	}
+	m.imageIDs.Store(image, id)
+	return id
+}
+
+// resumeOffset 确认续传位置并将本地文件定位到该处，无法续传时返回 0
+func (m *Manager) resumeOffset(sshClient *ssh.Client, target config.Target, file *os.File, size int64, remoteTarPath string, progress *mpb.Progress) int64 {
+	offset, err := sshClient.ResumeOffset(file, size, remoteTarPath)
+	if err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 检查续传位置失败: %v，从头上传\n", target.Name, err)
+		return 0
+	}
+	if offset == 0 {
+		return 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		fmt.Fprintf(progress, "  ⚠️  [%s] 定位本地文件失败: %v，从头上传\n", target.Name, err)
+		return 0
+	}
+	fmt.Fprintf(progress, "  ⏩ [%s] 远程已有 %s / %s，从断点续传\n", target.Name, formatBytes(offset), formatBytes(size))
+	return offset
+}
+
+// uploadPath 返回远程临时目录中 tar 文件的路径，压缩上传时加上对应的扩展名
+func uploadPath(target config.Target, tarFile, codec string) string {
+	return path.Join(target.RemoteTempDir, filepath.Base(tarFile)) + compress.Extension(codec)
+}
+
+// loadUploaded 上传完成后的步骤：执行 hooks、加载镜像、保存远程缓存、清理远程 tar 文件
+// cacheTar 为与远程 tar 文件内容相同的本地完整镜像，开启二进制增量时保存为下次传输的基准文件，为空表示不保存
+func (m *Manager) loadUploaded(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, remoteTarPath, codec, cacheTar string, progress *mpb.Progress) error {
+	// 4. 执行hooks（全局 + 镜像级）
+	vars := map[string]string{"image": imageCfg.Name}
+
+	// 4a/4b. 执行全局和镜像级 pre_load hooks（增量加载失败改传完整镜像时不再重复执行）
+	m.runPreLoad(sshClient, target, imageCfg)
+
+	// 5. 根据配置决定是否加载Docker镜像
 	if m.cfg.Transfer.AutoLoad {
-		if err := sshClient.LoadDockerImage(remoteTarPath); err != nil {
+		if err := sshClient.LoadDockerImage(remoteTarPath, codec); err != nil {
 			return err
 		}
 
-		// 7. 执行post_load hooks（全局 + 镜像级）
-		// 7a. 执行全局 post_load hooks
+		// 6. 执行post_load hooks（全局 + 镜像级）
+		// 6a. 执行全局 post_load hooks
 		if len(m.cfg.Hooks.PostLoad) > 0 {
 			sshClient.ExecuteHooks("post_load", m.cfg.Hooks.PostLoad, vars)
 		}
-		// 7b. 执行镜像级 post_load hooks
+		// 6b. 执行镜像级 post_load hooks
 		if len(imageCfg.Hooks.PostLoad) > 0 {
 			sshClient.ExecuteHooks("post_load", imageCfg.Hooks.PostLoad, vars)
 		}
 	}
 
+	// 7. 开启二进制增量时保存到远程缓存
+	if cacheTar != "" {
+		m.updateCache(sshClient, target, imageCfg, cacheTar, remoteTarPath, progress)
+	}
+
 	// 8. 根据配置决定是否清理远程tar文件
 	if m.cfg.RemoteStorage.AutoCleanup {
 		sshClient.RemoveRemoteFile(remoteTarPath)
@@ -311,3 +567,71 @@ func (m *Manager) doTransfer(host string, imageCfg config.ImageConfig, tarFile s
 
 	return nil
 }
+
+// doStreamTransfer 流式传输：本地 docker save 的输出经SSH会话直接写入远程 docker load，两端都不落盘
+// 任一端失败时终止另一端：远程失败时关闭本地 docker save，本地失败时终止远程 docker load
+func (m *Manager) doStreamTransfer(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig, codec string, progress *mpb.Progress) (*TransferStats, error) {
+	// 1. 执行 pre_load hooks（全局 + 镜像级），被脱离批量分发后第一次单独传输时不再执行
+	m.runPreLoad(sshClient, target, imageCfg)
+
+	// 2. 启动本地 docker save，并将输出（按配置压缩后）流式导入远程 docker load
+	stream, err := m.dockerClient.SaveImageStream(imageCfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	ws, err := newWireSource(stream, codec, m.cfg.Transfer.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Close()
+
+	var estimate int64
+	if !ws.compressed() {
+		estimate = m.dockerClient.ImageSize(imageCfg.Name)
+	}
+	if err := sshClient.LoadDockerImageStream(ws, estimate, codec, progress); err != nil {
+		return nil, err
+	}
+	stats := ws.stats()
+
+	// 3. 执行 post_load hooks（全局 + 镜像级）
+	m.runHooks(sshClient, "post_load", m.cfg.Hooks.PostLoad, imageCfg.Hooks.PostLoad, imageCfg)
+	return stats, nil
+}
+
+// runHooks 依次执行全局和镜像级 hooks
+func (m *Manager) runHooks(sshClient *ssh.Client, stage string, global, image []string, imageCfg config.ImageConfig) {
+	vars := map[string]string{"image": imageCfg.Name}
+	if len(global) > 0 {
+		sshClient.ExecuteHooks(stage, global, vars)
+	}
+	if len(image) > 0 {
+		sshClient.ExecuteHooks(stage, image, vars)
+	}
+}
+
+// runPreLoad 执行 pre_load hooks，每次尝试执行一次
+// 被脱离批量分发或增量加载失败改传完整镜像时，本次尝试已经执行过 hooks，接下来的传输不再执行；之后的重试照常执行
+func (m *Manager) runPreLoad(sshClient *ssh.Client, target config.Target, imageCfg config.ImageConfig) {
+	if _, done := m.preLoaded.LoadAndDelete(preLoadKey(target, imageCfg)); done {
+		return
+	}
+	m.runHooks(sshClient, "pre_load", m.cfg.Hooks.PreLoad, imageCfg.Hooks.PreLoad, imageCfg)
+}
+
+// preLoadKey 返回记录 pre_load hooks 执行情况的键
+func preLoadKey(target config.Target, imageCfg config.ImageConfig) string {
+	return target.Name + "\x00" + imageCfg.Name
+}
+
+// verifying 是否在加载前校验上传文件的 SHA-256（流式传输没有远程文件，不校验）
+func (m *Manager) verifying() bool {
+	return m.cfg.Transfer.VerifyChecksum && !m.streaming()
+}
+
+// streaming 是否为流式传输模式
+func (m *Manager) streaming() bool {
+	return m.cfg.Transfer.Mode == "stream"
+}